@@ -3,10 +3,10 @@ package main
 import (
 	"bytes"
 	"compress/zlib"
-	"encoding/binary"
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
 	"io"
 	"log"
@@ -24,6 +24,17 @@ type element struct {
 	Y     int16
 	W     int
 	H     int
+	Z     float64
+	// Parent is the id of the element this one is positioned relative to, if any
+	Parent   string
+	Children []string
+	// Collision is set for elements that become collision objects
+	Collision *collision
+	// Properties override the script properties of game object instances
+	Properties []component
+
+	// layer is the index of the aseprite layer the element came from
+	layer int
 }
 
 type animation struct {
@@ -35,15 +46,52 @@ type animation struct {
 
 type asepriteImporter struct {
 	outputDir string
+	// Level layers are spread between these z values, from the bottom layer to the top
+	zMin, zMax float64
+	// mergeCollision merges the tiles of collision layers into boxes, unless merge=false is in their user data
+	mergeCollision bool
+	// autoAnchor anchors UI nodes to the screen edges they're closest to, unless they have anchors
+	autoAnchor bool
 }
 
 func (a asepriteImporter) Import(filenames []string) error {
 	var (
 		animations []animation
 		uiNodes    []element
+		uiAnims    []animation
 		datas      []string
+		// levels are the names of every level collection, in the order they were imported
+		levels []string
+		// screens are the names of every gui, and templates the guis used as templates by others
+		screens   []string
+		templates = make(map[string]bool)
 	)
 	for _, file := range filenames {
+		// e.g. assets/ui/start.aseprite becomes dir=assets/ui and name=start
+		dir, name := filepath.Split(file)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		// Levels can also be designed in tiled or LDtk
+		if ext := filepath.Ext(file); (ext == ".tmx" || ext == ".ldtk") && !strings.HasSuffix(dir, "levels/") {
+			log.Printf("WARNING: skipping %s, tiled maps and LDtk projects are only imported from levels", file)
+			continue
+		}
+		switch filepath.Ext(file) {
+		case ".tmx":
+			data, err := a.importTiled(file, name, len(datas))
+			if err != nil {
+				return err
+			}
+			datas = append(datas, data...)
+			levels = append(levels, name)
+			continue
+		case ".ldtk":
+			names, err := a.importLDtk(file, name)
+			if err != nil {
+				return err
+			}
+			levels = append(levels, names...)
+			continue
+		}
 		var aseFile asefile.AsepriteFile
 		if err := aseFile.DecodeFile(file); err != nil {
 			return fmt.Errorf("failed to decode %s: %s", file, err)
@@ -51,15 +99,26 @@ func (a asepriteImporter) Import(filenames []string) error {
 		if aseFile.Header.ColorDepth != 32 {
 			return fmt.Errorf("unsupported color depth %d. please convert to RGBA", aseFile.Header.ColorDepth)
 		}
-		// e.g. assets/ui/start.aseprite becomes dir=assets/ui and name=start
-		dir, name := filepath.Split(file)
-		name = strings.TrimSuffix(name, filepath.Ext(name))
+		fileData, err := readUserData(file)
+		if err != nil {
+			return fmt.Errorf("failed to read the user data of %s: %s", file, err)
+		}
+		fileData.setLayers(&aseFile)
 		if strings.HasSuffix(dir, "ui/") {
-			ui, err := a.importUI(name, aseFile)
+			ui, anims, used, err := a.importUI(name, aseFile)
 			if err != nil {
 				return err
 			}
 			uiNodes = append(uiNodes, ui...)
+			uiAnims = append(uiAnims, anims...)
+			screens = append(screens, name)
+			for _, template := range used {
+				templates[template] = true
+			}
+		} else if strings.HasSuffix(dir, "fonts/") {
+			if err := a.importFont(name, aseFile); err != nil {
+				return err
+			}
 		} else if strings.HasSuffix(dir, "sprites/") {
 			anim, err := a.importSprite(name, aseFile)
 			if err != nil {
@@ -67,11 +126,12 @@ func (a asepriteImporter) Import(filenames []string) error {
 			}
 			animations = append(animations, anim...)
 		} else if strings.HasSuffix(dir, "levels/") {
-			data, err := a.importLevel(name, len(datas), aseFile)
+			data, rooms, err := a.importLevel(name, len(datas), aseFile, fileData)
 			if err != nil {
 				return err
 			}
 			datas = append(datas, data...)
+			levels = append(levels, rooms...)
 		} else {
 			log.Printf("no support for importing %s yet", file)
 		}
@@ -82,12 +142,42 @@ func (a asepriteImporter) Import(filenames []string) error {
 	if err := a.writeFile("data.script", bytes.NewBufferString(`go.property("data", 1)`)); err != nil {
 		return err
 	}
+	// Levels are loaded through a proxy each, so adding a level needs no other changes
+	if len(levels) > 0 {
+		if err := a.render("levels.collection", levelsCollectionTemplate, levels); err != nil {
+			return err
+		}
+		if err := a.render("levels.lua", levelsTemplate, levels); err != nil {
+			return err
+		}
+	}
+	// Every gui that isn't only a template is in ui.collection too, so screens can be switched without setting them up
+	var uiScreens []string
+	for _, screen := range screens {
+		if screen == "ui" {
+			log.Printf("WARNING: leaving the gui ui out of ui.collection, ui is the id of the game object switching screens")
+		} else if !templates[screen] {
+			uiScreens = append(uiScreens, screen)
+		}
+	}
+	if len(uiScreens) > 0 {
+		if err := a.render("ui.collection", screensCollectionTemplate, uiScreens); err != nil {
+			return err
+		}
+		if err := a.render("ui.script", screensScriptTemplate, uiScreens); err != nil {
+			return err
+		}
+	}
 	// Combine all game animations into single atlas for performance
 	if err := a.render("all.atlas", animationsTemplate, animations); err != nil {
 		return err
 	}
 	// Also all UI nodes
-	if err := a.render("ui.atlas", atlasTemplate, uiNodes); err != nil {
+	ui := struct {
+		Images     []element
+		Animations []animation
+	}{uiNodes, uiAnims}
+	if err := a.render("ui.atlas", uiAtlasTemplate, ui); err != nil {
 		return err
 	}
 	return nil
@@ -108,20 +198,15 @@ func (a asepriteImporter) importSprite(filename string, file asefile.AsepriteFil
 			if tag.Repeats == 1 {
 				anim.PlaybackMode = "PLAYBACK_ONCE_FORWARD"
 			}
-			var duration uint16
+			var frames []int
 			for i := tag.FromFrame; i <= tag.ToFrame; i++ {
-				frameDuration := file.Frames[i].FrameDurationMilliseconds
-				if duration == 0 {
-					duration = frameDuration
-				} else if duration != frameDuration {
-					log.Printf("WARNING: frame duration inconsistency for animation %s: wanted %d, got %d", anim.ID, duration, frameDuration)
-				}
 				anim.Frames = append(anim.Frames, fmt.Sprintf("%s_%d.png", filename, i))
+				frames = append(frames, int(i))
 			}
-			if duration == 0 {
-				return anims, fmt.Errorf("unexpected zero animation duration for %s", anim.ID)
+			var err error
+			if anim.FPS, err = animationFPS(file, anim.ID, frames); err != nil {
+				return anims, err
 			}
-			anim.FPS = 1000 / duration
 			anims = append(anims, anim)
 		}
 	}
@@ -131,199 +216,48 @@ func (a asepriteImporter) importSprite(filename string, file asefile.AsepriteFil
 	return anims, nil
 }
 
-func (a asepriteImporter) importLevel(filename string, dataOffset int, file asefile.AsepriteFile) ([]string, error) {
-	var (
-		objects  []element
-		triggers []element
-		tiles    []element
-		datas    []string
-	)
-	for _, frame := range file.Frames {
-		tilesets := make(map[uint32]asefile.AsepriteTilesetChunk2023)
-		for _, tileset := range frame.Tilesets {
-			tilesets[tileset.TilesetID] = tileset
-			if tileset.Name == "" {
-				continue
-			}
-			if err := a.writeTilesetPNG(fmt.Sprintf("img/%s_tiles_%s.png", filename, tileset.Name), tileset); err != nil {
-				return nil, err
-			}
-		}
-		for _, cel := range frame.Cels {
-			const (
-				Image   = 2
-				Tilemap = 3
-			)
-			switch cel.CelType {
-			case Image:
-				layer := frame.Layers[cel.LayerIndex].LayerName
-				var objectName string
-				if strings.HasSuffix(layer, ".object") {
-					objectName = strings.TrimSuffix(layer, ".object")
-				}
-				centerX := cel.X + int16(cel.WidthInPix)/2
-				centerY := cel.Y + int16(cel.HeightInPix)/2
-				// y coordinates are reversed in defold
-				y := int16(file.Header.HeightInPixels) - centerY
-				if objectName != "" {
-					objects = append(objects, element{
-						X:    centerX,
-						Y:    y,
-						Name: objectName,
-					})
-					continue
-				}
-				if err := a.writePNG(fmt.Sprintf("img/%s_%s.png", filename, layer), cel); err != nil {
-					return nil, err
-				}
-				objects = append(objects, element{
-					Group: filename,
-					Name:  layer,
-					X:     centerX,
-					Y:     y,
-					W:     int(cel.WidthInPix),
-					H:     int(cel.HeightInPix),
-				})
-			case Tilemap:
-				layer := frame.Layers[cel.LayerIndex].LayerName
-				tileset, ok := tilesets[frame.Layers[cel.LayerIndex].TilesetIndex]
-				if !ok {
-					continue
-				}
-				var objectName string
-				if strings.HasSuffix(layer, ".object") {
-					objectName = strings.TrimSuffix(layer, ".object")
-				}
-				r := bytes.NewReader(cel.Tiles)
-				for y := 0; y < int(cel.HeightInTiles); y++ {
-					for x := 0; x < int(cel.WidthInTiles); x++ {
-						var tileIndex uint32
-						if err := binary.Read(r, binary.LittleEndian, &tileIndex); err != nil {
-							return nil, fmt.Errorf("failed to read tile data: %s", err)
-						}
-						if tileIndex > 0 {
-							x := cel.X + int16(x)*int16(tileset.TileWidth) + int16(tileset.TileWidth)/2
-							// y coordinates are reversed in defold
-							y := int16(file.Header.HeightInPixels) - cel.Y - int16(y)*int16(tileset.TileHeight)
-							if objectName != "" {
-								objects = append(objects, element{
-									X:     x,
-									Y:     y,
-									Name:  objectName,
-									Index: len(objects) + 1,
-								})
-							} else {
-								tiles = append(tiles, element{
-									X:     x / int16(tileset.TileWidth),
-									Y:     y/int16(tileset.TileHeight) - 1,
-									Index: int(tileIndex),
-								})
-							}
-						}
-					}
-				}
-			default:
-				log.Printf("unsupported cel type %d", cel.CelType)
-			}
-		}
-		for _, slice := range frame.Slices {
-			for _, data := range slice.SliceKeysData {
-				centerX := int16(data.SliceXOriginCoords) + int16(data.SliceWidth)/2
-				centerY := int16(data.SliceYOriginCoords) + int16(data.SliceHeight)/2
-				triggers = append(triggers, element{
-					Index: dataOffset + 1,
-					Group: filename,
-					Name:  slice.Name,
-					X:     centerX,
-					// y coordinates are reversed in defold
-					Y: int16(file.Header.HeightInPixels) - centerY,
-					// For some reason box2d shapes end up twice the width you specify
-					W: int(data.SliceWidth / 2),
-					H: int(data.SliceHeight / 2),
-				})
-				dataOffset++
-				datas = append(datas, slice.Name)
-			}
+// animationFPS returns the speed of an animation from the duration of its frames, which should all be the same
+func animationFPS(file asefile.AsepriteFile, id string, frames []int) (uint16, error) {
+	var duration uint16
+	for _, f := range frames {
+		frameDuration := file.Frames[f].FrameDurationMilliseconds
+		if duration == 0 {
+			duration = frameDuration
+		} else if duration != frameDuration {
+			log.Printf("WARNING: frame duration inconsistency for animation %s: wanted %d, got %d", id, duration, frameDuration)
 		}
 	}
-	var level struct {
-		Filename string
-		Objects  []element
-		Triggers []element
-		Tiles    []element
-	}
-	level.Filename = filename
-	level.Objects = objects
-	level.Triggers = triggers
-	level.Tiles = tiles
-	if err := a.render(filename+".atlas", atlasTemplate, level.Objects); err != nil {
-		return nil, err
-	}
-	if len(tiles) > 0 {
-		if err := a.render(filename+".tilemap", tilemapTemplate, level); err != nil {
-			return nil, err
-		}
+	if duration == 0 {
+		return 0, fmt.Errorf("unexpected zero animation duration for %s", id)
 	}
-	return datas, a.render(filename+".collection", collectionTemplate, level)
+	return 1000 / duration, nil
 }
 
-func (a asepriteImporter) importUI(filename string, file asefile.AsepriteFile) ([]element, error) {
-	var gui struct {
-		Textures []string
-		Elements []element
-	}
-	gui.Textures = append(gui.Textures, "ui")
-	needsAllTextures := false
-	for _, frame := range file.Frames {
-		for _, cel := range frame.Cels {
-			layer := frame.Layers[cel.LayerIndex].LayerName
-			if err := a.writePNG(fmt.Sprintf("img/%s_%s.png", filename, layer), cel); err != nil {
-				return nil, err
-			}
-			gui.Elements = append(gui.Elements, element{
-				Group: filename,
-				Name:  layer,
-				X:     cel.X,
-				// y coordinates are reversed in defold
-				Y: int16(file.Header.HeightInPixels) - cel.Y,
-				W: int(cel.WidthInPix),
-				H: int(cel.HeightInPix),
-			})
-		}
-		if len(frame.Slices) > 0 {
-			needsAllTextures = true
-
-			for _, slice := range frame.Slices {
-				for _, key := range slice.SliceKeysData {
-					gui.Elements = append(gui.Elements, element{
-						Name: slice.Name,
-						X:    int16(key.SliceXOriginCoords),
-						// y coordinates are reversed in defold
-						Y: int16(file.Header.HeightInPixels) - int16(key.SliceYOriginCoords),
-						W: int(key.SliceWidth),
-						H: int(key.SliceHeight),
-					})
+// frameCels returns the cels of a frame, with linked cels replaced by the cel they link to
+func frameCels(file asefile.AsepriteFile, frame int) []asefile.AsepriteCelChunk2005 {
+	const Linked = 1
+	var cels []asefile.AsepriteCelChunk2005
+	for _, cel := range file.Frames[frame].Cels {
+		if cel.CelType == Linked && int(cel.FramePosToLinkWith) < len(file.Frames) {
+			for _, linked := range file.Frames[cel.FramePosToLinkWith].Cels {
+				if linked.LayerIndex == cel.LayerIndex {
+					cel = linked
 				}
 			}
 		}
+		cels = append(cels, cel)
 	}
-	if needsAllTextures {
-		gui.Textures = append(gui.Textures, "all")
-	}
-	if err := a.render(filename+".gui", guiTemplate, gui); err != nil {
-		return nil, err
-	}
-	return gui.Elements, nil
+	return cels
 }
 
-func (a asepriteImporter) writeTilesetPNG(filename string, tileset asefile.AsepriteTilesetChunk2023) error {
+func decodeTileset(tileset asefile.AsepriteTilesetChunk2023) (*image.RGBA, error) {
 	out, err := zlib.NewReader(bytes.NewReader(tileset.CompressedTilesetImg))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	data, err := io.ReadAll(out)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	w, h := int(tileset.TileWidth), int(tileset.NumTiles)*int(tileset.TileHeight)
 	img := image.NewRGBA(image.Rect(0, 0, w, h))
@@ -334,6 +268,16 @@ func (a asepriteImporter) writeTilesetPNG(filename string, tileset asefile.Asepr
 			img.SetRGBA(x, y, col)
 		}
 	}
+	return img, nil
+}
+
+// celImage returns the pixels of an image cel, positioned where the cel is on the canvas
+func celImage(cel asefile.AsepriteCelChunk2005) *image.RGBA {
+	rect := image.Rect(0, 0, int(cel.WidthInPix), int(cel.HeightInPix)).Add(image.Pt(int(cel.X), int(cel.Y)))
+	return &image.RGBA{Pix: cel.RawCelData, Stride: 4 * rect.Dx(), Rect: rect}
+}
+
+func (a asepriteImporter) writeImage(filename string, img image.Image) error {
 	buf := new(bytes.Buffer)
 	if err := png.Encode(buf, img); err != nil {
 		return err
@@ -352,14 +296,8 @@ func (a asepriteImporter) writePNG(filename string, cels ...asefile.AsepriteCelC
 	}
 	img := image.NewRGBA(image.Rect(0, 0, w, h))
 	for _, cel := range cels {
-		offset := 0
-		data := cel.RawCelData
-		for y := 0; y < h; y++ {
-			for x := 0; x < w; x, offset = x+1, offset+4 {
-				col := color.RGBA{data[offset], data[offset+1], data[offset+2], data[offset+3]}
-				img.SetRGBA(x, y, col)
-			}
-		}
+		src := celImage(cel)
+		draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
 	}
 	buf := new(bytes.Buffer)
 	if err := png.Encode(buf, img); err != nil {
@@ -9,7 +9,9 @@ material: "/builtins/materials/sprite.material"
 blend_mode: BLEND_MODE_ALPHA
 `))
 
-var atlasTemplate = template.Must(template.New("").Parse(`
+// atlasParts are the parts atlases are made of, shared by the atlas templates below
+const atlasParts = `
+{{- define "images" }}
 {{- range . }}
 {{- if .Group }}
 images {
@@ -18,13 +20,9 @@ sprite_trim_mode: SPRITE_TRIM_MODE_OFF
 }
 {{- end }}
 {{ end -}}
-margin: 2
-extrude_borders: 0
-inner_padding: 0
-`))
-
-var animationsTemplate = template.Must(template.New("").Parse(`
-{{- range .}}
+{{ end }}
+{{- define "animations" }}
+{{- range . }}
 animations {
   id: "{{ .ID }}"
   {{- range .Frames }}
@@ -39,7 +37,23 @@ animations {
   flip_vertical: 0
 }
 {{ end -}}
+{{ end }}
+{{- define "settings" -}}
 margin: 2
 extrude_borders: 0
 inner_padding: 0
-`))
+{{ end }}`
+
+var atlasTemplate = template.Must(template.New("").Parse(atlasParts + `
+{{- template "images" . }}
+{{- template "settings" }}`))
+
+var animationsTemplate = template.Must(template.New("").Parse(atlasParts + `
+{{- template "animations" . }}
+{{- template "settings" }}`))
+
+// uiAtlasTemplate has both images and animations
+var uiAtlasTemplate = template.Must(template.New("").Parse(atlasParts + `
+{{- template "images" .Images }}
+{{- template "animations" .Animations }}
+{{- template "settings" }}`))
@@ -0,0 +1,32 @@
+package main
+
+import "text/template"
+
+var fntTemplate = template.Must(template.New("").Parse(`info face="{{ .Name }}" size={{ .Size }} bold=0 italic=0 charset="" unicode=1 stretchH=100 smooth=0 aa=0 padding=0,0,0,0 spacing=1,1
+common lineHeight={{ .LineHeight }} base={{ .Base }} scaleW={{ .Width }} scaleH={{ .Height }} pages=1 packed=0
+page id=0 file="img/{{ .Name }}_font.png"
+chars count={{ len .Glyphs }}
+{{- range .Glyphs }}
+char id={{ .ID }} x={{ .X }} y={{ .Y }} width={{ .W }} height={{ .H }} xoffset={{ .XOffset }} yoffset={{ .YOffset }} xadvance={{ .Advance }} page=0 chnl=15
+{{- end }}
+`))
+
+var fontTemplate = template.Must(template.New("").Parse(`
+font: "/import/{{ .Name }}.fnt"
+material: "/builtins/fonts/font.material"
+size: {{ .Size }}
+antialias: 0
+alpha: 1.0
+outline_alpha: 0.0
+outline_width: 0.0
+shadow_alpha: 0.0
+shadow_blur: 0
+shadow_x: 0.0
+shadow_y: 0.0
+extra_characters: ""
+output_format: TYPE_BITMAP
+all_chars: false
+cache_width: 0
+cache_height: 0
+render_mode: MODE_SINGLE_LAYER
+`))
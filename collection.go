@@ -5,10 +5,35 @@ import "text/template"
 var collectionTemplate = template.Must(template.New("").Parse(`
 name: "{{ .Filename }}"
 scale_along_z: 0
+{{- range .Groups }}
+embedded_instances {
+  id: "{{ .ID }}"
+  {{- range .Children }}
+  children: "{{ . }}"
+  {{- end }}
+  data: ""
+  position {
+    x: {{ .X }}.0
+    y: {{ .Y }}.0
+    z: 0.0
+  }
+  rotation {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+    w: 1.0
+  }
+  scale3 {
+    x: 1.0
+    y: 1.0
+    z: 1.0
+  }
+}
+{{- end }}
 {{- range .Objects }}
 {{- if .Group }}
 embedded_instances {
-  id: "{{ .Name }}"
+  id: "{{ .ID }}"
   data: "embedded_components {\n"
   "  id: \"sprite\"\n"
   "  type: \"sprite\"\n"
@@ -33,7 +58,7 @@ embedded_instances {
   position {
     x: {{ .X }}.0
     y: {{ .Y }}.0
-    z: 0.0
+    z: {{ printf "%.3f" .Z }}
   }
   rotation {
     x: 0.0
@@ -49,12 +74,12 @@ embedded_instances {
 }
 {{- else }}
 instances {
-  id: "{{ .Name }}{{ or .Index "" }}"
+  id: "{{ .ID }}"
   prototype: "/game/objects/{{ .Name }}.go"
   position {
     x: {{ .X }}.0
     y: {{ .Y }}.0
-    z: 0.4
+    z: {{ printf "%.3f" .Z }}
   }
   rotation {
     x: 0.0
@@ -62,6 +87,18 @@ instances {
     z: 0.0
     w: 1.0
   }
+  {{- range .Properties }}
+  component_properties {
+    id: "{{ .ID }}"
+    {{- range .Properties }}
+    properties {
+      id: "{{ .ID }}"
+      value: {{ printf "%q" .Value }}
+      type: {{ .Type }}
+    }
+    {{- end }}
+  }
+  {{- end }}
   scale3 {
     x: 1.0
     y: 1.0
@@ -99,15 +136,17 @@ embedded_instances {
   "  id: \"collisionobject\"\n"
   "  type: \"collisionobject\"\n"
   "  data: \"collision_shape: \\\"\\\"\\n"
-  "type: COLLISION_OBJECT_TYPE_TRIGGER\\n"
-  "mass: 0.0\\n"
+  "type: {{ .Collision.Type }}\\n"
+  "mass: {{ if eq .Collision.Type "COLLISION_OBJECT_TYPE_DYNAMIC" }}1.0{{ else }}0.0{{ end }}\\n"
   "friction: 0.1\\n"
   "restitution: 0.5\\n"
-  "group: \\\"trigger\\\"\\n"
-  "mask: \\\"player\\\"\\n"
+  "group: \\\"{{ .Collision.Group }}\\\"\\n"
+  {{- range .Collision.Mask }}
+  "mask: \\\"{{ . }}\\\"\\n"
+  {{- end }}
   "embedded_collision_shape {\\n"
   "  shapes {\\n"
-  "    shape_type: TYPE_BOX\\n"
+  "    shape_type: {{ .Collision.Shape }}\\n"
   "    position {\\n"
   "      x: 0.0\\n"
   "      y: 0.0\\n"
@@ -120,11 +159,11 @@ embedded_instances {
   "      w: 1.0\\n"
   "    }\\n"
   "    index: 0\\n"
-  "    count: 3\\n"
+  "    count: {{ len .Collision.Data }}\\n"
   "  }\\n"
-  "  data: {{ .W }}.0\\n"
-  "  data: {{ .H }}.0\\n"
-  "  data: 10.0\\n"
+  {{- range .Collision.Data }}
+  "  data: {{ printf "%.1f" . }}\\n"
+  {{- end }}
   "}\\n"
   "linear_damping: 0.0\\n"
   "angular_damping: 0.0\\n"
@@ -162,4 +201,80 @@ embedded_instances {
   }
 }
 {{ end }}
+{{- range .Solids }}
+embedded_instances {
+  id: "{{ .ID }}"
+  data: "embedded_components {\n"
+  "  id: \"collisionobject\"\n"
+  "  type: \"collisionobject\"\n"
+  "  data: \"collision_shape: \\\"\\\"\\n"
+  "type: {{ .Type }}\\n"
+  "mass: 0.0\\n"
+  "friction: 0.1\\n"
+  "restitution: 0.5\\n"
+  "group: \\\"{{ .Group }}\\\"\\n"
+  {{- range .Mask }}
+  "mask: \\\"{{ . }}\\\"\\n"
+  {{- end }}
+  "embedded_collision_shape {\\n"
+  {{- range .Boxes }}
+  "  shapes {\\n"
+  "    shape_type: TYPE_BOX\\n"
+  "    position {\\n"
+  "      x: {{ printf "%.1f" .X }}\\n"
+  "      y: {{ printf "%.1f" .Y }}\\n"
+  "      z: 0.0\\n"
+  "    }\\n"
+  "    rotation {\\n"
+  "      x: 0.0\\n"
+  "      y: 0.0\\n"
+  "      z: 0.0\\n"
+  "      w: 1.0\\n"
+  "    }\\n"
+  "    index: {{ .Index }}\\n"
+  "    count: 3\\n"
+  "  }\\n"
+  {{- end }}
+  {{- range .Boxes }}
+  "  data: {{ printf "%.1f" .HalfW }}\\n"
+  "  data: {{ printf "%.1f" .HalfH }}\\n"
+  "  data: 10.0\\n"
+  {{- end }}
+  "}\\n"
+  "linear_damping: 0.0\\n"
+  "angular_damping: 0.0\\n"
+  "locked_rotation: false\\n"
+  "bullet: false\\n"
+  "\"\n"
+  "  position {\n"
+  "    x: 0.0\n"
+  "    y: 0.0\n"
+  "    z: 0.0\n"
+  "  }\n"
+  "  rotation {\n"
+  "    x: 0.0\n"
+  "    y: 0.0\n"
+  "    z: 0.0\n"
+  "    w: 1.0\n"
+  "  }\n"
+  "}\n"
+  ""
+  position {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+  }
+  rotation {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+    w: 1.0
+  }
+  scale3 {
+    x: 1.0
+    y: 1.0
+    z: 1.0
+  }
+}
+{{- end }}
 `))
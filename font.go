@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pranavraja/asefile"
+)
+
+type glyph struct {
+	ID      rune
+	X, Y    int
+	W, H    int
+	XOffset int
+	YOffset int
+	Advance int
+
+	// rect is where the glyph is on the aseprite canvas, and baseline the row of its baseline within it
+	rect     image.Rectangle
+	baseline int
+}
+
+type bitmapFont struct {
+	Name       string
+	Size       int
+	LineHeight int
+	Base       int
+	Width      int
+	Height     int
+	Glyphs     []glyph
+}
+
+// glyphRune returns the character a glyph slice is named after, e.g. "A", "space" or "U+00E9"
+func glyphRune(name string) (rune, bool) {
+	if name == "space" {
+		return ' ', true
+	}
+	if code, ok := strings.CutPrefix(name, "U+"); ok {
+		r, err := strconv.ParseUint(code, 16, 32)
+		return rune(r), err == nil
+	}
+	if utf8.RuneCountInString(name) != 1 {
+		return 0, false
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	return r, true
+}
+
+// importFont packs the glyphs of a font, one slice per character, into a BMFont and a defold font using it.
+// The pivot of a slice is where the next glyph starts on the baseline, otherwise the glyph sits on the bottom
+// of its slice and advances by its width plus one. User data like "advance=6 baseline=5" overrides both
+func (a asepriteImporter) importFont(filename string, file asefile.AsepriteFile) error {
+	const (
+		Visible = 1
+		// layer opacity is only set in files with this header flag
+		LayerOpacity = 1
+	)
+	var (
+		layers []asefile.AsepriteLayerChunk2004
+		slices []asefile.AsepriteSliceChunk2022
+	)
+	for _, frame := range file.Frames {
+		layers = append(layers, frame.Layers...)
+		slices = append(slices, frame.Slices...)
+	}
+	// The glyphs are cut from the first frame with all visible image layers flattened
+	canvas := image.NewRGBA(image.Rect(0, 0, int(file.Header.WidthInPixels), int(file.Header.HeightInPixels)))
+	for _, cel := range frameCels(file, 0) {
+		if cel.CelType != 2 || int(cel.LayerIndex) >= len(layers) || layers[cel.LayerIndex].Flags&Visible == 0 {
+			continue
+		}
+		opacity := int(cel.OpacityLevel)
+		if file.Header.Flags&LayerOpacity != 0 {
+			opacity = opacity * int(layers[cel.LayerIndex].Opacity) / 255
+		}
+		img := celImage(cel)
+		draw.DrawMask(canvas, img.Bounds(), img, img.Bounds().Min, image.NewUniform(color.Alpha{uint8(opacity)}), image.Point{}, draw.Over)
+	}
+	font := bitmapFont{Name: filename}
+	glyphs := make(map[rune]string)
+	for _, slice := range slices {
+		id, ok := glyphRune(slice.Name)
+		if !ok {
+			return fmt.Errorf("slice %s in %s: expected a single character, space or U+<hex code>", slice.Name, filename)
+		}
+		if other, ok := glyphs[id]; ok {
+			return fmt.Errorf("slices %s and %s in %s are both the glyph %q", other, slice.Name, filename, id)
+		}
+		glyphs[id] = slice.Name
+		keys := sliceKeys(slice, 0, 0)
+		if len(keys) == 0 {
+			continue
+		}
+		key := keys[0]
+		x, y := int(key.SliceXOriginCoords), int(key.SliceYOriginCoords)
+		g := glyph{
+			ID:       id,
+			W:        int(key.SliceWidth),
+			H:        int(key.SliceHeight),
+			rect:     image.Rect(x, y, x+int(key.SliceWidth), y+int(key.SliceHeight)),
+			Advance:  int(key.SliceWidth) + 1,
+			baseline: int(key.SliceHeight),
+		}
+		const HasPivot = 2
+		if slice.Flags&HasPivot != 0 {
+			g.Advance, g.baseline = int(key.PivotX), int(key.PivotY)
+		}
+		data := userData(fmt.Sprintf("slice %s in %s", slice.Name, filename), slice.UserData.Text)
+		var err error
+		numbers := map[string]*int{"advance": &g.Advance, "baseline": &g.baseline}
+		for key, value := range numbers {
+			if v, ok := data[key]; ok {
+				if *value, err = strconv.Atoi(v); err != nil {
+					return fmt.Errorf("slice %s in %s: invalid %s %s", slice.Name, filename, key, v)
+				}
+			}
+		}
+		font.Glyphs = append(font.Glyphs, g)
+		font.Base = max(font.Base, g.baseline)
+	}
+	if len(font.Glyphs) == 0 {
+		return fmt.Errorf("no glyph slices in font %s", filename)
+	}
+	hasSpace, advances := false, 0
+	for i := range font.Glyphs {
+		g := &font.Glyphs[i]
+		g.YOffset = font.Base - g.baseline
+		font.LineHeight = max(font.LineHeight, g.YOffset+g.H)
+		hasSpace = hasSpace || g.ID == ' '
+		advances += g.Advance
+	}
+	// Without a space slice, spaces are as wide as the average glyph
+	if !hasSpace {
+		font.Glyphs = append(font.Glyphs, glyph{ID: ' ', Advance: advances / len(font.Glyphs)})
+	}
+	font.Size = font.LineHeight
+	sheet := packGlyphs(font.Glyphs)
+	font.Width, font.Height = sheet.Dx(), sheet.Dy()
+	img := image.NewRGBA(sheet)
+	for _, g := range font.Glyphs {
+		draw.Draw(img, image.Rect(g.X, g.Y, g.X+g.W, g.Y+g.H), canvas, g.rect.Min, draw.Src)
+	}
+	sort.Slice(font.Glyphs, func(i, j int) bool { return font.Glyphs[i].ID < font.Glyphs[j].ID })
+	if err := a.writeImage(fmt.Sprintf("img/%s_font.png", filename), img); err != nil {
+		return err
+	}
+	if err := a.render(filename+".fnt", fntTemplate, font); err != nil {
+		return err
+	}
+	return a.render(filename+".font", fontTemplate, font)
+}
+
+// packGlyphs places the glyphs in rows from the tallest to the shortest, with a pixel between them,
+// on a sheet with a power of two width. It returns the bounds of the sheet
+func packGlyphs(glyphs []glyph) image.Rectangle {
+	const Padding = 1
+	order := make([]int, len(glyphs))
+	area, widest := 0, 0
+	for i, g := range glyphs {
+		order[i] = i
+		area += (g.W + Padding) * (g.H + Padding)
+		widest = max(widest, g.W+Padding)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return glyphs[order[i]].H > glyphs[order[j]].H })
+	width := 1
+	for width*width < area || width < widest {
+		width *= 2
+	}
+	x, y, row := 0, 0, 0
+	for _, i := range order {
+		g := &glyphs[i]
+		if x+g.W+Padding > width {
+			x, y, row = 0, y+row, 0
+		}
+		g.X, g.Y = x, y
+		x += g.W + Padding
+		row = max(row, g.H+Padding)
+	}
+	return image.Rect(0, 0, width, y+row)
+}
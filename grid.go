@@ -0,0 +1,95 @@
+package main
+
+import "text/template"
+
+var gridTemplate = template.Must(template.New("").Parse(`
+{{- define "runs" }}expand({ {{- range $i, $v := .Runs }}{{ if $i }}, {{ end }}{{ $v }}{{ end -}} }){{ end }}
+local grid = {}
+grid.width = {{ .Width }}
+grid.height = {{ .Height }}
+grid.tile_width = {{ .TileWidth }}
+grid.tile_height = {{ .TileHeight }}
+
+-- expand decodes run-length encoded cells, pairs of a count and a value
+local function expand(runs)
+	local cells = {}
+	for i = 1, #runs, 2 do
+		for _ = 1, runs[i] do
+			cells[#cells + 1] = runs[i + 1]
+		end
+	end
+	return cells
+end
+
+-- the cost of every cell, row by row from the bottom of the level. 0 can't be walked over
+{{- if .Cells.Runs }}
+local cells = {{ template "runs" .Cells }}
+{{- else }}
+local cells = {
+{{- range .Cells.Rows }}
+	{{ range $i, $v := . }}{{ if $i }}, {{ end }}{{ $v }}{{ end }},
+{{- end }}
+}
+{{- end }}
+grid.cells = cells
+
+-- the cells of layers exported as values, like LDtk IntGrid layers, in the same order. 0 is empty
+grid.values = {
+{{- range $id, $cells := .Values }}
+	{{- if $cells.Runs }}
+	[{{ printf "%q" $id }}] = {{ template "runs" $cells }},
+	{{- else }}
+	[{{ printf "%q" $id }}] = {
+	{{- range $cells.Rows }}
+		{{ range $i, $v := . }}{{ if $i }}, {{ end }}{{ $v }}{{ end }},
+	{{- end }}
+	},
+	{{- end }}
+{{- end }}
+}
+
+-- cost returns the cost of the cell at x, y, as passed to tilemap.get_tile, or nil outside the level
+function grid.cost(x, y)
+	if x < 1 or y < 1 or x > grid.width or y > grid.height then
+		return nil
+	end
+	return cells[(y - 1) * grid.width + x]
+end
+
+-- value returns the value of a layer in grid.values at x, y, or nil outside the level
+function grid.value(layer, x, y)
+	if x < 1 or y < 1 or x > grid.width or y > grid.height then
+		return nil
+	end
+	return grid.values[layer][(y - 1) * grid.width + x]
+end
+
+function grid.walkable(x, y)
+	local cost = grid.cost(x, y)
+	return cost ~= nil and cost > 0
+end
+
+-- cell returns the cell at a position in the level
+function grid.cell(x, y)
+	return math.floor(x / grid.tile_width) + 1, math.floor(y / grid.tile_height) + 1
+end
+
+-- position returns the center of a cell in the level
+function grid.position(x, y)
+	return (x - 0.5) * grid.tile_width, (y - 0.5) * grid.tile_height
+end
+
+-- neighbours returns the walkable cells next to x, y as a list of { x, y, cost }
+function grid.neighbours(x, y)
+	local result = {}
+	for _, d in ipairs({ { 1, 0 }, { -1, 0 }, { 0, 1 }, { 0, -1 } }) do
+		local cost = grid.cost(x + d[1], y + d[2])
+		if cost and cost > 0 then
+			result[#result + 1] = { x + d[1], y + d[2], cost }
+		end
+	end
+	return result
+end
+
+return grid
+`))
@@ -3,24 +3,47 @@ package main
 import "text/template"
 
 var guiTemplate = template.Must(template.New("").Parse(`
-script: ""
+script: "/import/{{ .Name }}.gui_script"
 {{ range .Textures }}
 textures {
   name: "{{ . }}"
   texture: "/import/{{ . }}.atlas"
 }
 {{ end }}
+{{- range .Fonts }}
+fonts {
+  name: "{{ .Name }}"
+  font: "{{ .Path }}"
+}
+{{- end }}
 background_color {
   x: 0.0
   y: 0.0
   z: 0.0
   w: 0.0
 }
-{{ range .Elements }}
+{{ range .Nodes }}
 nodes {
+{{- template "node" . }}
+}
+{{ end }}
+{{- range .Layouts }}
+layouts {
+  name: "{{ .Name }}"
+  {{- range .Nodes }}
+  nodes {
+  {{- template "node" . }}
+  }
+  {{- end }}
+}
+{{ end -}}
+material: "/builtins/materials/gui.material"
+adjust_reference: ADJUST_REFERENCE_PARENT
+max_nodes: 512
+{{- define "node" }}
   position {
-    x: {{ .X }}.0
-    y: {{ .Y }}.0
+    x: {{ printf "%.1f" .X }}
+    y: {{ printf "%.1f" .Y }}
     z: 0.0
     w: 1.0
   }
@@ -43,23 +66,50 @@ nodes {
     w: 1.0
   }
   color {
-    x: 1.0
-    y: 1.0
-    z: 1.0
-    w: 1.0
+    x: {{ printf "%.3f" (index .Color 0) }}
+    y: {{ printf "%.3f" (index .Color 1) }}
+    z: {{ printf "%.3f" (index .Color 2) }}
+    w: {{ printf "%.3f" (index .Color 3) }}
   }
-  type: TYPE_BOX
+  type: {{ .Type }}
   blend_mode: BLEND_MODE_ALPHA
-  {{ if .Group }}
-  texture: "ui/{{ .Group }}_{{ .Name }}"
-  {{ else }}
-  texture: ""
-  {{ end }}
-  id: "{{ .Name }}"
-  xanchor: XANCHOR_NONE
-  yanchor: YANCHOR_NONE
-  pivot: PIVOT_NW
-  adjust_mode: ADJUST_MODE_FIT
+  {{- if eq .Type "TYPE_TEXT" }}
+  text: {{ printf "%q" .Text }}
+  font: "{{ .Font }}"
+  outline {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+    w: 1.0
+  }
+  shadow {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+    w: 1.0
+  }
+  line_break: false
+  text_leading: 1.0
+  text_tracking: 0.0
+  {{- end }}
+  {{- if eq .Type "TYPE_PIE" }}
+  outerBounds: {{ .PieBounds }}
+  innerRadius: {{ printf "%.1f" .InnerRadius }}
+  perimeterVertices: {{ .Vertices }}
+  pieFillAngle: {{ printf "%.1f" .FillAngle }}
+  {{- end }}
+  {{- if .Template }}
+  template: "/import/{{ .Template }}.gui"
+  {{- end }}
+  texture: "{{ .Texture }}"
+  id: "{{ .ID }}"
+  {{- if .Parent }}
+  parent: "{{ .Parent }}"
+  {{- end }}
+  xanchor: {{ .XAnchor }}
+  yanchor: {{ .YAnchor }}
+  pivot: {{ .Pivot }}
+  adjust_mode: {{ .Adjust }}
   layer: ""
   inherit_alpha: true
   slice9 {
@@ -68,22 +118,127 @@ nodes {
     z: 0.0
     w: 0.0
   }
+  {{- if .Clipping }}
+  clipping_mode: CLIPPING_MODE_STENCIL
+  clipping_visible: {{ .ClipVisible }}
+  clipping_inverted: {{ .ClipInverted }}
+  {{- else }}
   clipping_mode: CLIPPING_MODE_NONE
   clipping_visible: true
   clipping_inverted: false
+  {{- end }}
   alpha: 1.0
   template_node_child: false
-  {{ if .Group }}
+  {{ if .Texture }}
   size_mode: SIZE_MODE_AUTO
   {{ else }}
   size_mode: SIZE_MODE_MANUAL
   {{ end }}
   custom_type: 0
   enabled: true
-  visible: true
+  visible: {{ not .Hidden }}
+  {{- range .Overrides }}
+  overridden_fields: {{ . }}
+  {{- end }}
+{{- end }}
+`))
+
+// guiObjectTemplate is a game object holding a gui, to add it to a collection
+var guiObjectTemplate = template.Must(template.New("").Parse(`
+components {
+  id: "gui"
+  component: "/import/{{ .Name }}.gui"
 }
-{{ end }}
-material: "/builtins/materials/gui.material"
-adjust_reference: ADJUST_REFERENCE_PARENT
-max_nodes: 512
+`))
+
+var guiNodesTemplate = template.Must(template.New("").Parse(`
+local nodes = {}
+-- ids are the hashed ids of every node
+nodes.ids = {
+{{- range .Nodes }}
+	[{{ printf "%q" .ID }}] = hash({{ printf "%q" .ID }}),
+{{- end }}
+}
+-- buttons are the nodes checked for clicks by nodes.input
+nodes.buttons = {
+{{- range .Nodes }}
+{{- if .Button }}
+	nodes.ids[{{ printf "%q" .ID }}],
+{{- end }}
+{{- end }}
+}
+-- states are the images of the buttons by state
+nodes.states = {
+{{- range .Nodes }}
+{{- if .States }}
+	[nodes.ids[{{ printf "%q" .ID }}]] = {
+		{{- range .States }}
+		{{ .State }} = hash({{ printf "%q" .Image }}),
+		{{- end }}
+	},
+{{- end }}
+{{- end }}
+}
+local current = {}
+local disabled = {}
+
+-- set_state shows the image of a button in a state, normal, hover, pressed or disabled,
+-- if it has one. The state of disabled buttons doesn't change until they're enabled
+function nodes.set_state(id, state)
+	local states = nodes.states[id]
+	if not states or current[id] == state or (disabled[id] and state ~= "disabled") then
+		return
+	end
+	current[id] = state
+	gui.play_flipbook(gui.get_node(id), states[state] or states.normal)
+end
+
+function nodes.set_disabled(id, is_disabled)
+	disabled[id] = is_disabled or nil
+	nodes.set_state(id, is_disabled and "disabled" or "normal")
+end
+
+-- input updates the states of the buttons, and returns the id of the button that was clicked, if any
+function nodes.input(action_id, action)
+	-- key and gamepad actions have no position to pick buttons with
+	if action.x == nil then
+		return nil
+	end
+	local clicked
+	for _, id in ipairs(nodes.buttons) do
+		local picked = not disabled[id] and gui.pick_node(gui.get_node(id), action.x, action.y)
+		if picked and action_id == hash("touch") and action.pressed then
+			nodes.set_state(id, "pressed")
+		elseif picked and action_id == hash("touch") and action.released then
+			nodes.set_state(id, "hover")
+			clicked = id
+		elseif picked and current[id] ~= "pressed" then
+			nodes.set_state(id, "hover")
+		elseif not picked and not disabled[id] then
+			nodes.set_state(id, "normal")
+		end
+	end
+	return clicked
+end
+return nodes
+`))
+
+var guiScriptTemplate = template.Must(template.New("").Parse(`
+local nodes = require("import.{{ .Name }}_nodes")
+
+function init(self)
+	msg.post(".", "acquire_input_focus")
+end
+
+-- on_click is called with the id of the button that was clicked
+local function on_click(self, id)
+end
+
+function on_input(self, action_id, action)
+	local clicked = nodes.input(action_id, action)
+	if clicked then
+		on_click(self, clicked)
+		return true
+	end
+end
 `))
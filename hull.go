@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image"
+	"sort"
+)
+
+// maxHullVertices matches the largest polygon box2d accepts by default
+const maxHullVertices = 8
+
+// convexHull returns the convex hull around the opaque pixels of rect,
+// counter clockwise, relative to the center of rect with y pointing up like defold.
+// An empty slice is returned for fully transparent tiles
+func convexHull(img *image.RGBA, rect image.Rectangle) []float64 {
+	var corners []image.Point
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		left, right := -1, -1
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if img.RGBAAt(x, y).A == 0 {
+				continue
+			}
+			if left < 0 {
+				left = x
+			}
+			right = x
+		}
+		if left < 0 {
+			continue
+		}
+		// Only the outermost pixels of each row can be on the hull
+		corners = append(corners,
+			image.Pt(left, y), image.Pt(right+1, y),
+			image.Pt(left, y+1), image.Pt(right+1, y+1),
+		)
+	}
+	if len(corners) == 0 {
+		return nil
+	}
+	hull := monotoneChain(corners)
+	if len(hull) > maxHullVertices {
+		// Too detailed for box2d, the bounding box is a good enough approximation
+		// not Union, which skips the empty rectangles of single points
+		bounds := image.Rectangle{Min: hull[0], Max: hull[0]}
+		for _, p := range hull {
+			bounds.Min.X, bounds.Min.Y = min(bounds.Min.X, p.X), min(bounds.Min.Y, p.Y)
+			bounds.Max.X, bounds.Max.Y = max(bounds.Max.X, p.X), max(bounds.Max.Y, p.Y)
+		}
+		hull = []image.Point{
+			{bounds.Min.X, bounds.Min.Y},
+			{bounds.Max.X, bounds.Min.Y},
+			{bounds.Max.X, bounds.Max.Y},
+			{bounds.Min.X, bounds.Max.Y},
+		}
+	}
+	cx, cy := float64(rect.Min.X+rect.Max.X)/2, float64(rect.Min.Y+rect.Max.Y)/2
+	var points []float64
+	// Flipping y also flips the winding, so walk the hull backwards to stay counter clockwise
+	for i := len(hull) - 1; i >= 0; i-- {
+		// y coordinates are reversed in defold
+		points = append(points, float64(hull[i].X)-cx, cy-float64(hull[i].Y))
+	}
+	return points
+}
+
+// monotoneChain computes the convex hull of points, dropping collinear points.
+// The result is counter clockwise in the coordinate space of points
+func monotoneChain(points []image.Point) []image.Point {
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].X != points[j].X {
+			return points[i].X < points[j].X
+		}
+		return points[i].Y < points[j].Y
+	})
+	cross := func(o, a, b image.Point) int {
+		return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+	}
+	hull := make([]image.Point, 0, 2*len(points))
+	for _, p := range points {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(points) - 2; i >= 0; i-- {
+		p := points[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
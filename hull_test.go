@@ -0,0 +1,141 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// tileImage returns an image of a vertical strip of tiles, with the pixels where opaque returns true set
+func tileImage(w, h int, opaque func(x, y int) bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if opaque(x, y) {
+				img.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestConvexHull(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *image.RGBA
+		rect image.Rectangle
+		want []float64
+	}{
+		{
+			name: "transparent",
+			img:  tileImage(4, 4, func(x, y int) bool { return false }),
+			rect: image.Rect(0, 0, 4, 4),
+			want: nil,
+		},
+		{
+			name: "full tile",
+			img:  tileImage(4, 4, func(x, y int) bool { return true }),
+			rect: image.Rect(0, 0, 4, 4),
+			want: []float64{-2, -2, 2, -2, 2, 2, -2, 2},
+		},
+		{
+			name: "second tile of a strip",
+			img:  tileImage(4, 8, func(x, y int) bool { return y >= 4 }),
+			rect: image.Rect(0, 4, 4, 8),
+			want: []float64{-2, -2, 2, -2, 2, 2, -2, 2},
+		},
+		{
+			// the pixels along the diagonal are collinear corners that are dropped
+			name: "slope",
+			img:  tileImage(4, 4, func(x, y int) bool { return x <= y }),
+			rect: image.Rect(0, 0, 4, 4),
+			want: []float64{-2, -2, 2, -2, 2, -1, -1, 2, -2, 2},
+		},
+		{
+			name: "top half",
+			img:  tileImage(4, 4, func(x, y int) bool { return y < 2 }),
+			rect: image.Rect(0, 0, 4, 4),
+			want: []float64{-2, 0, 2, 0, 2, 2, -2, 2},
+		},
+		{
+			// a circle has more vertices than box2d accepts, so it becomes its bounding box
+			name: "too many vertices",
+			img: tileImage(16, 16, func(x, y int) bool {
+				dx, dy := float64(x)-7.5, float64(y)-7.5
+				return dx*dx+dy*dy < 36
+			}),
+			rect: image.Rect(0, 0, 16, 16),
+			want: []float64{-6, -6, 6, -6, 6, 6, -6, 6},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := convexHull(test.img, test.rect)
+			if !equalPoints(got, test.want) {
+				t.Errorf("convexHull = %v, want %v", got, test.want)
+			}
+			if area := signedArea(got); len(got) > 0 && area <= 0 {
+				t.Errorf("convexHull = %v is clockwise, signed area %g", got, area)
+			}
+		})
+	}
+}
+
+func TestMonotoneChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []image.Point
+		want   []image.Point
+	}{
+		{
+			name:   "duplicates",
+			points: []image.Point{{0, 0}, {0, 0}, {2, 0}, {2, 0}, {0, 2}, {2, 2}, {0, 2}},
+			want:   []image.Point{{0, 0}, {2, 0}, {2, 2}, {0, 2}},
+		},
+		{
+			name:   "collinear",
+			points: []image.Point{{0, 0}, {1, 0}, {2, 0}, {2, 1}, {2, 2}, {1, 2}, {0, 2}, {0, 1}, {1, 1}},
+			want:   []image.Point{{0, 0}, {2, 0}, {2, 2}, {0, 2}},
+		},
+		{
+			name:   "triangle",
+			points: []image.Point{{0, 0}, {4, 0}, {2, 1}, {0, 4}},
+			want:   []image.Point{{0, 0}, {4, 0}, {0, 4}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := monotoneChain(test.points)
+			if len(got) != len(test.want) {
+				t.Fatalf("monotoneChain = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("monotoneChain = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func equalPoints(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// signedArea is positive for counter clockwise polygons, with y pointing up
+func signedArea(points []float64) float64 {
+	var area float64
+	for i := 0; i < len(points); i += 2 {
+		j := (i + 2) % len(points)
+		area += points[i]*points[j+1] - points[j]*points[i+1]
+	}
+	return area / 2
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pranavraja/asefile"
+)
+
+// ldtkProject and the types below mirror the parts of LDtk's JSON format the importer uses
+type ldtkProject struct {
+	WorldLayout string `json:"worldLayout"`
+	Defs        struct {
+		Tilesets []ldtkTileset `json:"tilesets"`
+	} `json:"defs"`
+	Levels []ldtkLevel `json:"levels"`
+	// Worlds are only set in projects with multiple worlds, which aren't supported
+	Worlds []json.RawMessage `json:"worlds"`
+}
+
+type ldtkTileset struct {
+	UID          int    `json:"uid"`
+	Identifier   string `json:"identifier"`
+	RelPath      string `json:"relPath"`
+	TileGridSize int    `json:"tileGridSize"`
+	Spacing      int    `json:"spacing"`
+	Padding      int    `json:"padding"`
+	CWid         int    `json:"__cWid"`
+	CHei         int    `json:"__cHei"`
+}
+
+type ldtkLevel struct {
+	Identifier      string      `json:"identifier"`
+	WorldX          int         `json:"worldX"`
+	WorldY          int         `json:"worldY"`
+	WorldDepth      int         `json:"worldDepth"`
+	PxWid           int         `json:"pxWid"`
+	PxHei           int         `json:"pxHei"`
+	ExternalRelPath string      `json:"externalRelPath"`
+	LayerInstances  []ldtkLayer `json:"layerInstances"`
+}
+
+// ldtkLayer is a layer instance of a level. LDtk lists them from the top layer down
+type ldtkLayer struct {
+	Identifier      string       `json:"__identifier"`
+	Type            string       `json:"__type"`
+	CWid            int          `json:"__cWid"`
+	CHei            int          `json:"__cHei"`
+	GridSize        int          `json:"__gridSize"`
+	TilesetDefUID   *int         `json:"__tilesetDefUid"`
+	OffsetX         int          `json:"__pxTotalOffsetX"`
+	OffsetY         int          `json:"__pxTotalOffsetY"`
+	Visible         bool         `json:"visible"`
+	IntGridCsv      []int        `json:"intGridCsv"`
+	AutoLayerTiles  []ldtkTile   `json:"autoLayerTiles"`
+	GridTiles       []ldtkTile   `json:"gridTiles"`
+	EntityInstances []ldtkEntity `json:"entityInstances"`
+}
+
+type ldtkTile struct {
+	Px [2]int `json:"px"`
+	// F is 1 for an x flip, 2 for a y flip or 3 for both
+	F int `json:"f"`
+	T int `json:"t"`
+}
+
+type ldtkEntity struct {
+	Identifier     string      `json:"__identifier"`
+	Pivot          [2]float64  `json:"__pivot"`
+	Px             [2]int      `json:"px"`
+	Width          int         `json:"width"`
+	Height         int         `json:"height"`
+	FieldInstances []ldtkField `json:"fieldInstances"`
+}
+
+type ldtkField struct {
+	Identifier string `json:"__identifier"`
+	Type       string `json:"__type"`
+	Value      any    `json:"__value"`
+}
+
+// ldtkWorld is the Lua index of where each level is in the world
+type ldtkWorld struct {
+	Layout string
+	Levels []ldtkWorldLevel
+}
+
+type ldtkWorldLevel struct {
+	Name  string
+	X, Y  int
+	W, H  int
+	Depth int
+}
+
+// importLDtk imports every level of an LDtk project like a level made in aseprite, see importLevel.
+// Tile and auto layers become tilemap layers, and entities become instances with their fields as
+// script properties. The values of IntGrid layers are exported in the grid of the level, see newWalkGrid.
+// It returns the names of the levels
+func (a asepriteImporter) importLDtk(file, filename string) ([]string, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var project ldtkProject
+	if err := json.Unmarshal(contents, &project); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %s", file, err)
+	}
+	if len(project.Levels) == 0 && len(project.Worlds) > 0 {
+		return nil, fmt.Errorf("%s has multiple worlds, which are not supported yet, please use one world per project", file)
+	}
+	sources := make(map[int]*tilesource)
+	for _, tileset := range project.Defs.Tilesets {
+		if tileset.RelPath == "" {
+			// e.g. the internal icons tileset
+			continue
+		}
+		f, err := os.Open(filepath.Join(filepath.Dir(file), tileset.RelPath))
+		if err != nil {
+			return nil, err
+		}
+		decoded, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("tileset %s in %s: %s", tileset.Identifier, file, err)
+		}
+		img := image.NewRGBA(decoded.Bounds())
+		draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+		source := &tilesource{
+			Name:       fmt.Sprintf("%s_%s", filename, tileset.Identifier),
+			Image:      fmt.Sprintf("%s_tiles_%s", filename, tileset.Identifier),
+			TileWidth:  uint16(tileset.TileGridSize),
+			TileHeight: uint16(tileset.TileGridSize),
+			NumTiles:   tileset.CWid * tileset.CHei,
+			Columns:    tileset.CWid,
+			Margin:     tileset.Padding,
+			Spacing:    tileset.Spacing,
+			img:        img,
+			groups:     make(map[int]string),
+		}
+		if err := a.writeImage(fmt.Sprintf("img/%s.png", source.Image), img); err != nil {
+			return nil, err
+		}
+		if err := a.render(source.Name+".tilesource", tilesourceTemplate, source); err != nil {
+			return nil, err
+		}
+		sources[tileset.UID] = source
+	}
+	var names []string
+	world := ldtkWorld{Layout: project.WorldLayout}
+	for _, lvl := range project.Levels {
+		if lvl.ExternalRelPath != "" {
+			path := filepath.Join(filepath.Dir(file), lvl.ExternalRelPath)
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(contents, &lvl); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %s", path, err)
+			}
+		}
+		name := fmt.Sprintf("%s_%s", filename, lvl.Identifier)
+		if err := a.importLDtkLevel(name, lvl, sources); err != nil {
+			return nil, fmt.Errorf("level %s in %s: %s", lvl.Identifier, file, err)
+		}
+		names = append(names, name)
+		world.Levels = append(world.Levels, ldtkWorldLevel{
+			Name: name,
+			X:    lvl.WorldX,
+			// y coordinates are reversed in defold, this is the bottom of the level
+			Y:     -lvl.WorldY - lvl.PxHei,
+			W:     lvl.PxWid,
+			H:     lvl.PxHei,
+			Depth: lvl.WorldDepth,
+		})
+	}
+	return names, a.render(filename+"_world.lua", ldtkWorldTemplate, world)
+}
+
+func (a asepriteImporter) importLDtkLevel(name string, lvl ldtkLevel, sources map[int]*tilesource) error {
+	// LDtk lists layers from the top down, aseprite from the bottom up
+	var (
+		layers []asefile.AsepriteLayerChunk2004
+		data   []map[string]string
+		source []ldtkLayer
+	)
+	for i := len(lvl.LayerInstances) - 1; i >= 0; i-- {
+		layer := lvl.LayerInstances[i]
+		layers = append(layers, asefile.AsepriteLayerChunk2004{LayerName: layer.Identifier})
+		data = append(data, map[string]string{})
+		source = append(source, layer)
+	}
+	z, err := a.layerDepths(layers, data)
+	if err != nil {
+		return err
+	}
+	var (
+		objects []element
+		tilemap []*tilemapLayer
+		meta    = levelMeta{
+			Width:   lvl.PxWid,
+			Height:  lvl.PxHei,
+			Objects: make(map[string][]element),
+			Slices:  make(map[string][]element),
+			Paths:   make(map[string][]waypoint),
+		}
+		// A defold tilemap can only reference one tilesource
+		tilemapSource *tilesource
+		height        = lvl.PxHei
+		values        []valueLayer
+		gridSize      int
+	)
+	for i, layer := range source {
+		if layer.Type == "Entities" {
+			for _, entity := range layer.EntityInstances {
+				// px is where the pivot of the entity is
+				centerX := layer.OffsetX + entity.Px[0] + int((0.5-entity.Pivot[0])*float64(entity.Width))
+				centerY := layer.OffsetY + entity.Px[1] + int((0.5-entity.Pivot[1])*float64(entity.Height))
+				objects = append(objects, element{
+					X: int16(centerX),
+					// y coordinates are reversed in defold
+					Y:          int16(height - centerY),
+					Name:       entity.Identifier,
+					Index:      len(objects) + 1,
+					Z:          z[i],
+					Properties: componentProperties(ldtkFields(entity.FieldInstances, layer.GridSize, height)),
+					layer:      i,
+				})
+			}
+			continue
+		}
+		// LDtk identifiers can't contain dots, so e.g. Walls_collision marks a collision layer
+		group, solid := strings.CutSuffix(strings.ToLower(layer.Identifier), "collision")
+		if layer.Type == "IntGrid" && len(layer.IntGridCsv) > 0 {
+			if gridSize != 0 && gridSize != layer.GridSize {
+				return fmt.Errorf("IntGrid layer %s has a grid size of %d, other layers have %d", layer.Identifier, layer.GridSize, gridSize)
+			}
+			gridSize = layer.GridSize
+			// LDtk lists the values from the top row down
+			values = append(values, valueLayer{ID: layer.Identifier, Cells: make([]int, len(layer.IntGridCsv)), solid: solid})
+			for j, value := range layer.IntGridCsv {
+				col, row := j%layer.CWid, j/layer.CWid
+				values[len(values)-1].Cells[(layer.CHei-1-row)*layer.CWid+col] = value
+			}
+		}
+		tiles := append(layer.GridTiles, layer.AutoLayerTiles...)
+		if len(tiles) == 0 {
+			continue
+		}
+		var layerSource *tilesource
+		if layer.TilesetDefUID != nil {
+			layerSource = sources[*layer.TilesetDefUID]
+		}
+		if layerSource == nil {
+			log.Printf("WARNING: skipping layer %s of %s, it has no tileset", layer.Identifier, name)
+			continue
+		}
+		if tilemapSource != nil && tilemapSource != layerSource {
+			return fmt.Errorf("level uses more than one tileset (%s and %s), please use one per level", tilemapSource.Name, layerSource.Name)
+		}
+		tilemapSource = layerSource
+		t := &tilemapLayer{ID: layer.Identifier, Z: z[i], Hidden: !layer.Visible}
+		if solid {
+			t.solid = true
+			if a.mergeCollision {
+				t.body = &collision{Type: "COLLISION_OBJECT_TYPE_STATIC", Group: strings.TrimSuffix(group, "_"), Mask: []string{"player"}}
+				if t.body.Group == "" {
+					t.body.Group = "solid"
+				}
+			}
+		}
+		for _, lt := range tiles {
+			cell := flippedTile(lt.T, lt.F&1 != 0, lt.F&2 != 0, false)
+			cell.X = int16((layer.OffsetX + lt.Px[0]) / layer.GridSize)
+			// y coordinates are reversed in defold
+			cell.Y = int16(layer.CHei - 1 - (layer.OffsetY+lt.Px[1])/layer.GridSize)
+			t.Tiles = append(t.Tiles, cell)
+		}
+		tilemap = append(tilemap, t)
+	}
+	if tilemapSource != nil && len(values) > 0 && (int(tilemapSource.TileWidth) != gridSize || int(tilemapSource.TileHeight) != gridSize) {
+		return fmt.Errorf("IntGrid layers have a grid size of %d, but the tiles of %s are %dx%d", gridSize, tilemapSource.Name, tilemapSource.TileWidth, tilemapSource.TileHeight)
+	}
+	// levels with tiles get the size of their tiles instead
+	meta.TileWidth, meta.TileHeight = gridSize, gridSize
+	l := level{
+		Filename: name,
+		Objects:  objects,
+		Layers:   tilemap,
+		Values:   values,
+	}
+	return a.writeLevel(l, tilemapSource, layers, meta)
+}
+
+// ldtkFields converts entity fields to the same form as aseprite user data. Points are
+// converted to positions in the level, and fields without a value or a defold equivalent are skipped
+func ldtkFields(fields []ldtkField, gridSize, height int) map[string]string {
+	values := make(map[string]string)
+	for _, field := range fields {
+		switch v := field.Value.(type) {
+		case nil:
+		case float64, bool, string:
+			values[field.Identifier] = fmt.Sprint(v)
+		case map[string]any:
+			cx, okX := v["cx"].(float64)
+			cy, okY := v["cy"].(float64)
+			if !okX || !okY {
+				log.Printf("WARNING: skipping field %s, %s fields are not supported", field.Identifier, field.Type)
+				continue
+			}
+			x := int(cx)*gridSize + gridSize/2
+			// y coordinates are reversed in defold
+			y := height - int(cy)*gridSize - gridSize/2
+			values[field.Identifier] = fmt.Sprintf("%d,%d,0", x, y)
+		default:
+			log.Printf("WARNING: skipping field %s, %s fields are not supported", field.Identifier, field.Type)
+		}
+	}
+	return values
+}
@@ -0,0 +1,1069 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pranavraja/asefile"
+)
+
+// tile is a single cell of a tilemap layer. The flip and rotation flags are
+// 0 or 1, as that is what defold expects
+type tile struct {
+	X        int16
+	Y        int16
+	Index    int
+	HFlip    int
+	VFlip    int
+	Rotate90 int
+}
+
+// tilemapLayer is a layer of the generated tilemap, one per aseprite tilemap layer
+type tilemapLayer struct {
+	ID     string
+	Z      float64
+	Hidden bool
+	Tiles  []tile
+
+	// solid layers block the cells they have tiles in, see newWalkGrid
+	solid bool
+	// cost is the cost of walking over the layer's tiles, 0 if the layer doesn't affect walking
+	cost int
+	// body is set when the layer's tiles are merged into the boxes of a static collision object
+	body *collision
+	// hulls is set when the layer's tiles collide through their convex hulls instead, see addCollision
+	hulls bool
+}
+
+// tilesource describes a generated .tilesource, backed by a vertical strip of tiles
+type tilesource struct {
+	Name       string
+	Image      string
+	TileWidth  uint16
+	TileHeight uint16
+	NumTiles   int
+	Columns    int
+	Margin     int
+	Spacing    int
+	// Collision is the image the collision shapes were derived from, if any
+	Collision       string
+	CollisionGroups []string
+	Hulls           []hull
+	HullPoints      []float64
+
+	img *image.RGBA
+	// data is the user data of the tileset, and tiles that of each of its tiles by index
+	data  map[string]string
+	tiles map[int]map[string]string
+	// groups assigns a collision group to tile indices, see importLevel
+	groups map[int]string
+}
+
+type hull struct {
+	Index int
+	Count int
+	Group string
+}
+
+// level is everything generated for a single collection
+type level struct {
+	Filename string
+	Tileset  string
+	// Groups are the game objects for aseprite layer groups, parents of Objects
+	Groups   []element
+	Objects  []element
+	Triggers []element
+	Layers   []*tilemapLayer
+	// Solids are the collision objects of merged collision layers
+	Solids []solidBody
+	// Values are layers exported in the grid instead of the tilemap, with as many cells as it
+	Values []valueLayer
+}
+
+// waypoint is a point of a path. Unlike elements it can be between pixels, e.g. on the center of one
+type waypoint struct {
+	Index int
+	X, Y  float64
+}
+
+// levelMeta describes a level for game code, e.g. for camera bounds or minimaps
+type levelMeta struct {
+	Width, Height         int
+	TileWidth, TileHeight int
+	// Objects are the positions of every object by name
+	Objects map[string][]element
+	// Slices are rectangles with their bottom left corner in X and Y
+	Slices map[string][]element
+	// Paths are waypoints in order, see pathWaypoint
+	Paths  map[string][]waypoint
+	Layers []string
+}
+
+// collision is the collision object generated for a slice
+type collision struct {
+	Type  string
+	Group string
+	Mask  []string
+	Shape string
+	// Data are the dimensions of Shape, as defold expects them
+	Data []float64
+}
+
+// room is a range of frames imported as its own level
+type room struct {
+	Name     string
+	From, To int
+}
+
+// layerKeys are the layer user data keys used by the importer,
+// the rest become script properties of the objects on the layer
+var layerKeys = []string{"z", "cost", "merge"}
+
+// tileKeys are the keys of layer, tileset and tile user data used by the importer, see assignGroup
+var tileKeys = append([]string{"group"}, layerKeys...)
+
+// levelFile is the state shared between all the rooms of a level file
+type levelFile struct {
+	name     string
+	file     asefile.AsepriteFile
+	layers   []asefile.AsepriteLayerChunk2004
+	z        []float64
+	data     []map[string]string
+	slices   []asefile.AsepriteSliceChunk2022
+	tilesets map[uint32]asefile.AsepriteTilesetChunk2023
+	sources  map[string]*tilesource
+	masks    map[string]*image.RGBA
+	// cels is the user data of the cels by frame and layer index
+	cels map[[2]int]string
+}
+
+// tilesetName returns the name of a tileset, aseprite leaves it empty unless it is renamed
+func tilesetName(tileset asefile.AsepriteTilesetChunk2023) string {
+	if tileset.Name == "" {
+		return fmt.Sprintf("tileset%d", tileset.TilesetID)
+	}
+	return tileset.Name
+}
+
+// celData returns the user data of a cel in a frame
+func (l *levelFile) celData(frame int, cel asefile.AsepriteCelChunk2005) map[string]string {
+	layer := l.layers[cel.LayerIndex].LayerName
+	return userData(fmt.Sprintf("cel of layer %s in frame %d of %s", layer, frame, l.name), l.cels[[2]int{frame, int(cel.LayerIndex)}])
+}
+
+// tileData returns the user data of a tile, on top of that of its tileset
+func tileData(source *tilesource, index int) map[string]string {
+	if source == nil {
+		return nil
+	}
+	return mergeUserData(source.data, source.tiles[index])
+}
+
+// levelRooms splits a level file named e.g. dungeon.rooms.aseprite into rooms. Every tag becomes
+// a room, or every frame if there are no tags. All frames of other files are one level, whatever their tags
+func levelRooms(name string, file asefile.AsepriteFile) []room {
+	base, ok := strings.CutSuffix(name, ".rooms")
+	if !ok {
+		return []room{{Name: name, From: 0, To: len(file.Frames) - 1}}
+	}
+	var rooms []room
+	if len(file.Frames) > 0 {
+		for _, tag := range file.Frames[0].Tags.Tags {
+			rooms = append(rooms, room{
+				Name: fmt.Sprintf("%s_%s", base, tag.TagName),
+				From: int(tag.FromFrame),
+				To:   int(tag.ToFrame),
+			})
+		}
+	}
+	if len(rooms) > 0 {
+		return rooms
+	}
+	for i := range file.Frames {
+		rooms = append(rooms, room{Name: fmt.Sprintf("%s_%d", base, i), From: i, To: i})
+	}
+	return rooms
+}
+
+// importLevel imports every room of a level file, see levelRooms. It returns the trigger data and the names of the rooms
+func (a asepriteImporter) importLevel(filename string, dataOffset int, file asefile.AsepriteFile, fileData asepriteUserData) ([]string, []string, error) {
+	l := levelFile{
+		name:     strings.TrimSuffix(filename, ".rooms"),
+		file:     file,
+		tilesets: make(map[uint32]asefile.AsepriteTilesetChunk2023),
+		sources:  make(map[string]*tilesource),
+		masks:    make(map[string]*image.RGBA),
+		cels:     fileData.cels,
+	}
+	// Layers, slices and tilesets are only stored once, usually in the first frame
+	for _, frame := range file.Frames {
+		l.layers = append(l.layers, frame.Layers...)
+		l.slices = append(l.slices, frame.Slices...)
+		for _, tileset := range frame.Tilesets {
+			tileset.Name = tilesetName(tileset)
+			l.tilesets[tileset.TilesetID] = tileset
+			img, err := decodeTileset(tileset)
+			if err != nil {
+				return nil, nil, err
+			}
+			// A tileset named e.g. ground.collision holds the collision masks for the tiles of ground
+			if name, ok := strings.CutSuffix(tileset.Name, ".collision"); ok {
+				l.masks[name] = img
+				if err := a.writeImage(fmt.Sprintf("img/%s_collision_%s.png", l.name, name), img); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			source := &tilesource{
+				Name:       fmt.Sprintf("%s_%s", l.name, tileset.Name),
+				Image:      fmt.Sprintf("%s_tiles_%s", l.name, tileset.Name),
+				TileWidth:  tileset.TileWidth,
+				TileHeight: tileset.TileHeight,
+				NumTiles:   int(tileset.NumTiles),
+				Columns:    1,
+				img:        img,
+				data:       userData(fmt.Sprintf("tileset %s in %s", tileset.Name, filename), fileData.tilesets[tileset.TilesetID]),
+				tiles:      make(map[int]map[string]string),
+				groups:     make(map[int]string),
+			}
+			for i, text := range fileData.tiles[tileset.TilesetID] {
+				source.tiles[i] = userData(fmt.Sprintf("tile %d of tileset %s in %s", i, tileset.Name, filename), text)
+			}
+			if err := a.writeImage(fmt.Sprintf("img/%s.png", source.Image), img); err != nil {
+				return nil, nil, err
+			}
+			l.sources[tileset.Name] = source
+		}
+	}
+	for _, layer := range l.layers {
+		l.data = append(l.data, userData(fmt.Sprintf("layer %s in %s", layer.LayerName, filename), layer.UserData.Text))
+	}
+	var err error
+	if l.z, err = a.layerDepths(l.layers, l.data); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	trimDepths(l.layers)
+	rooms := levelRooms(filename, file)
+	// With tags, frames outside of them aren't part of any room
+	covered := make([]bool, len(file.Frames))
+	for _, r := range rooms {
+		for i := r.From; i <= r.To && i < len(covered); i++ {
+			covered[i] = true
+		}
+	}
+	for i, ok := range covered {
+		if !ok {
+			log.Printf("WARNING: skipping frame %d of %s, it isn't in any tag", i, filename)
+		}
+	}
+	var datas, names []string
+	for _, r := range rooms {
+		data, err := a.importRoom(&l, r, dataOffset+len(datas))
+		if err != nil {
+			return nil, nil, err
+		}
+		datas = append(datas, data...)
+		names = append(names, r.Name)
+	}
+	// Rooms share the tilesources, so these are written once all rooms have added their collision groups
+	for name, source := range l.sources {
+		if len(source.groups) > 0 {
+			source.addCollision(l.masks[name], fmt.Sprintf("%s_collision_%s", l.name, name))
+		}
+		if err := a.render(source.Name+".tilesource", tilesourceTemplate, source); err != nil {
+			return nil, nil, err
+		}
+	}
+	return datas, names, nil
+}
+
+func (a asepriteImporter) importRoom(l *levelFile, r room, dataOffset int) ([]string, error) {
+	var (
+		objects  []element
+		triggers []element
+		layers   []*tilemapLayer
+		datas    []string
+		meta     = levelMeta{
+			Width:   int(l.file.Header.WidthInPixels),
+			Height:  int(l.file.Header.HeightInPixels),
+			Objects: make(map[string][]element),
+			Slices:  make(map[string][]element),
+			Paths:   make(map[string][]waypoint),
+		}
+		// A defold tilemap can only reference one tilesource
+		tilemapSource *tilesource
+		height        = int16(l.file.Header.HeightInPixels)
+	)
+	for f := r.From; f <= r.To; f++ {
+		for _, cel := range l.file.Frames[f].Cels {
+			const (
+				Image   = 2
+				Tilemap = 3
+			)
+			if int(cel.LayerIndex) >= len(l.layers) {
+				return nil, fmt.Errorf("cel in %s refers to unknown layer %d", r.Name, cel.LayerIndex)
+			}
+			switch cel.CelType {
+			case Image:
+				layer := l.layers[cel.LayerIndex].LayerName
+				var objectName string
+				if strings.HasSuffix(layer, ".object") {
+					objectName = strings.TrimSuffix(layer, ".object")
+				}
+				// Every pixel of a layer named e.g. guard1.path is a waypoint of the path guard1
+				if name, ok := strings.CutSuffix(layer, ".path"); ok {
+					if _, ok := meta.Paths[name]; !ok {
+						meta.Paths[name] = pathPixels(cel, height)
+					}
+					continue
+				}
+				centerX := cel.X + int16(cel.WidthInPix)/2
+				centerY := cel.Y + int16(cel.HeightInPix)/2
+				// y coordinates are reversed in defold
+				y := height - centerY
+				if objectName != "" {
+					objects = append(objects, element{
+						X:          centerX,
+						Y:          y,
+						Name:       objectName,
+						Z:          l.z[cel.LayerIndex],
+						Properties: componentProperties(mergeUserData(l.data[cel.LayerIndex], l.celData(f, cel)), layerKeys...),
+						layer:      int(cel.LayerIndex),
+					})
+					continue
+				}
+				if err := a.writePNG(fmt.Sprintf("img/%s_%s.png", r.Name, layer), cel); err != nil {
+					return nil, err
+				}
+				objects = append(objects, element{
+					Group: r.Name,
+					Name:  layer,
+					X:     centerX,
+					Y:     y,
+					W:     int(cel.WidthInPix),
+					H:     int(cel.HeightInPix),
+					Z:     l.z[cel.LayerIndex],
+					layer: int(cel.LayerIndex),
+				})
+			case Tilemap:
+				layer := l.layers[cel.LayerIndex].LayerName
+				tileset, ok := l.tilesets[l.layers[cel.LayerIndex].TilesetIndex]
+				if !ok {
+					continue
+				}
+				var (
+					objectName, collisionGroup string
+					body                       *collision
+					objectData                 map[string]string
+				)
+				if strings.HasSuffix(layer, ".object") {
+					objectName = strings.TrimSuffix(layer, ".object")
+					objectData = mergeUserData(l.data[cel.LayerIndex], l.celData(f, cel))
+				} else {
+					var err error
+					if collisionGroup, body, err = a.collisionLayer(layer, l.data[cel.LayerIndex]); err != nil {
+						return nil, fmt.Errorf("layer %s in %s: %s", layer, r.Name, err)
+					}
+					source, ok := l.sources[tileset.Name]
+					if !ok {
+						return nil, fmt.Errorf("tilemap layer %s in %s uses the collision tileset %s, please paint with %s instead", layer, r.Name, tileset.Name, strings.TrimSuffix(tileset.Name, ".collision"))
+					}
+					if tilemapSource != nil && tilemapSource != source {
+						return nil, fmt.Errorf("level %s uses more than one tileset (%s and %s), please split it into separate files", r.Name, tilemapSource.Name, source.Name)
+					}
+					tilemapSource = source
+				}
+				var tilemap *tilemapLayer
+				for _, existing := range layers {
+					if existing.ID == layer {
+						tilemap = existing
+					}
+				}
+				if tilemap == nil && objectName == "" {
+					cost, err := layerCost(l.data[cel.LayerIndex])
+					if err != nil {
+						return nil, fmt.Errorf("layer %s in %s: %s", layer, r.Name, err)
+					}
+					tilemap = &tilemapLayer{ID: layer, Z: l.z[cel.LayerIndex], solid: collisionGroup != "", hulls: collisionGroup != "" && body == nil, cost: cost, body: body}
+					layers = append(layers, tilemap)
+				}
+				reader := bytes.NewReader(cel.Tiles)
+				for y := 0; y < int(cel.HeightInTiles); y++ {
+					for x := 0; x < int(cel.WidthInTiles); x++ {
+						var value uint32
+						if err := binary.Read(reader, binary.LittleEndian, &value); err != nil {
+							return nil, fmt.Errorf("failed to read tile data: %s", err)
+						}
+						t := decodeTile(cel, value)
+						if t.Index > 0 {
+							x := cel.X + int16(x)*int16(tileset.TileWidth) + int16(tileset.TileWidth)/2
+							// y coordinates are reversed in defold
+							y := height - cel.Y - int16(y)*int16(tileset.TileHeight)
+							if objectName != "" {
+								objects = append(objects, element{
+									X:          x,
+									Y:          y,
+									Name:       objectName,
+									Index:      len(objects) + 1,
+									Z:          l.z[cel.LayerIndex],
+									Properties: componentProperties(mergeUserData(objectData, tileData(l.sources[tileset.Name], t.Index)), tileKeys...),
+									layer:      int(cel.LayerIndex),
+								})
+							} else {
+								t.X = x / int16(tileset.TileWidth)
+								t.Y = y/int16(tileset.TileHeight) - 1
+								tilemap.Tiles = append(tilemap.Tiles, t)
+								// merged layers collide through the rectangles of their body instead
+								if tilemap.hulls {
+									l.sources[tileset.Name].assignGroup(t.Index, collisionGroup)
+								}
+							}
+						}
+					}
+				}
+			default:
+				log.Printf("unsupported cel type %d", cel.CelType)
+			}
+		}
+	}
+	for _, slice := range l.slices {
+		if path, index, ok := pathWaypoint(slice.Name); ok {
+			for _, key := range sliceKeys(slice, r.From, r.To) {
+				centerX := float64(key.SliceXOriginCoords) + float64(key.SliceWidth)/2
+				centerY := float64(key.SliceYOriginCoords) + float64(key.SliceHeight)/2
+				// y coordinates are reversed in defold
+				meta.Paths[path] = append(meta.Paths[path], waypoint{Index: index, X: centerX, Y: float64(height) - centerY})
+			}
+			continue
+		}
+		for _, key := range sliceKeys(slice, r.From, r.To) {
+			centerX := int16(key.SliceXOriginCoords) + int16(key.SliceWidth)/2
+			centerY := int16(key.SliceYOriginCoords) + int16(key.SliceHeight)/2
+			trigger := element{
+				Index: dataOffset + 1,
+				Group: r.Name,
+				Name:  slice.Name,
+				X:     centerX,
+				// y coordinates are reversed in defold
+				Y: height - centerY,
+				// For some reason box2d shapes end up twice the width you specify
+				W: int(key.SliceWidth / 2),
+				H: int(key.SliceHeight / 2),
+			}
+			data := userData(fmt.Sprintf("slice %s in %s", slice.Name, r.Name), slice.UserData.Text)
+			if err := configureCollision(&trigger, data); err != nil {
+				return nil, fmt.Errorf("slice %s in %s: %s", slice.Name, r.Name, err)
+			}
+			triggers = append(triggers, trigger)
+			meta.Slices[trigger.Name] = append(meta.Slices[trigger.Name], element{
+				X: int16(key.SliceXOriginCoords),
+				// y coordinates are reversed in defold
+				Y: height - int16(key.SliceYOriginCoords) - int16(key.SliceHeight),
+				W: int(key.SliceWidth),
+				H: int(key.SliceHeight),
+			})
+			dataOffset++
+			datas = append(datas, trigger.Name)
+		}
+	}
+	lvl := level{
+		Filename: r.Name,
+		Objects:  objects,
+		Triggers: triggers,
+		Layers:   layers,
+	}
+	return datas, a.writeLevel(lvl, tilemapSource, l.layers, meta)
+}
+
+// writeLevel renders the collection, tilemap, atlas and metadata of a level. Object positions
+// are absolute, they are made relative to the group of their layer here
+func (a asepriteImporter) writeLevel(lvl level, source *tilesource, layers []asefile.AsepriteLayerChunk2004, meta levelMeta) error {
+	// Positions in the metadata are absolute, so it's filled in before objects are grouped
+	for _, object := range lvl.Objects {
+		meta.Objects[object.Name] = append(meta.Objects[object.Name], object)
+	}
+	for _, layer := range lvl.Layers {
+		meta.Layers = append(meta.Layers, layer.ID)
+	}
+	for _, path := range meta.Paths {
+		sort.SliceStable(path, func(i, j int) bool { return path[i].Index < path[j].Index })
+	}
+	lvl.Groups = groupObjects(layers, lvl.Objects)
+	for _, layer := range lvl.Layers {
+		if layer.body != nil {
+			lvl.Solids = append(lvl.Solids, mergeTiles(layer, source))
+		}
+	}
+	if err := a.render(lvl.Filename+".atlas", atlasTemplate, lvl.Objects); err != nil {
+		return err
+	}
+	if len(lvl.Layers) > 0 {
+		lvl.Tileset = source.Name
+		meta.TileWidth, meta.TileHeight = int(source.TileWidth), int(source.TileHeight)
+		if err := a.render(lvl.Filename+".tilemap", tilemapTemplate, lvl); err != nil {
+			return err
+		}
+		// Defold tilemaps collide on all their layers, so the hulls of the collision layers get their own tilemap
+		collisionMap := level{Tileset: source.Name}
+		for _, layer := range lvl.Layers {
+			if layer.hulls {
+				collisionMap.Layers = append(collisionMap.Layers, layer)
+			}
+		}
+		if len(collisionMap.Layers) > 0 {
+			if err := a.render(lvl.Filename+"_collision.tilemap", tilemapTemplate, collisionMap); err != nil {
+				return err
+			}
+		}
+	}
+	if err := a.render(lvl.Filename+"_meta.lua", metaTemplate, meta); err != nil {
+		return err
+	}
+	if grid, ok := newWalkGrid(lvl, meta); ok {
+		if err := a.render(lvl.Filename+"_grid.lua", gridTemplate, grid); err != nil {
+			return err
+		}
+	}
+	return a.render(lvl.Filename+".collection", collectionTemplate, lvl)
+}
+
+// layerDepths assigns every layer a z between zMin and zMax by its order in aseprite,
+// unless overridden by z=<value> in the layer user data or a name suffix like clouds@0.9, see trimDepths
+func (a asepriteImporter) layerDepths(layers []asefile.AsepriteLayerChunk2004, data []map[string]string) ([]float64, error) {
+	z := make([]float64, len(layers))
+	for i, layer := range layers {
+		z[i] = a.zMin + (a.zMax-a.zMin)*(float64(i)+0.5)/float64(len(layers))
+		override, hasOverride := data[i]["z"]
+		if name, suffix, ok := depthSuffix(layer.LayerName); ok {
+			layer.LayerName, override, hasOverride = name, suffix, true
+		}
+		if hasOverride {
+			var err error
+			if z[i], err = strconv.ParseFloat(override, 64); err != nil {
+				return nil, fmt.Errorf("invalid z for layer %s: %s", layer.LayerName, err)
+			}
+		}
+	}
+	return z, nil
+}
+
+// trimDepths removes the z suffixes read by layerDepths from the layer names
+func trimDepths(layers []asefile.AsepriteLayerChunk2004) {
+	for i := range layers {
+		if name, _, ok := depthSuffix(layers[i].LayerName); ok {
+			layers[i].LayerName = name
+		}
+	}
+}
+
+// depthSuffix splits a layer name like clouds@0.9 into clouds and 0.9, other names with an @ are left alone
+func depthSuffix(name string) (string, string, bool) {
+	i := strings.LastIndex(name, "@")
+	if i < 0 {
+		return name, "", false
+	}
+	if _, err := strconv.ParseFloat(name[i+1:], 64); err != nil {
+		return name, "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// ID is the id of the game object generated for e in a collection
+func (e element) ID() string {
+	if e.Group == "" && e.Index > 0 {
+		return fmt.Sprintf("%s%d", e.Name, e.Index)
+	}
+	return e.Name
+}
+
+// layerParents returns the index of the group containing each layer, or -1 for top level layers
+func layerParents(layers []asefile.AsepriteLayerChunk2004) []int {
+	parents := make([]int, len(layers))
+	// stack[n] is the last layer seen at child level n
+	var stack []int
+	for i, layer := range layers {
+		level := min(int(layer.LayerChildLevel), len(stack))
+		stack = append(stack[:level], i)
+		parents[i] = -1
+		if level > 0 {
+			parents[i] = stack[level-1]
+		}
+	}
+	return parents
+}
+
+// groupObjects creates a parent game object for every layer group containing objects,
+// and makes the positions of objects relative to their group.
+// A group is positioned at the center of everything inside it
+func groupObjects(layers []asefile.AsepriteLayerChunk2004, objects []element) []element {
+	const Group = 1
+	parents := layerParents(layers)
+	// bounds of the absolute positions of everything inside each group layer
+	bounds := make(map[int]image.Rectangle)
+	for _, object := range objects {
+		p := image.Pt(int(object.X), int(object.Y))
+		for g := parents[object.layer]; g >= 0; g = parents[g] {
+			if b, ok := bounds[g]; ok {
+				bounds[g] = b.Union(image.Rectangle{Min: p, Max: p})
+			} else {
+				bounds[g] = image.Rectangle{Min: p, Max: p}
+			}
+		}
+	}
+	center := func(g int) (int16, int16) {
+		if g < 0 {
+			return 0, 0
+		}
+		c := bounds[g].Min.Add(bounds[g].Max).Div(2)
+		return int16(c.X), int16(c.Y)
+	}
+	var groups []element
+	index := make(map[int]int)
+	for i, layer := range layers {
+		if _, ok := bounds[i]; !ok || layer.LayerType != Group {
+			continue
+		}
+		x, y := center(i)
+		px, py := center(parents[i])
+		group := element{Name: layer.LayerName, X: x - px, Y: y - py, layer: i}
+		if parents[i] >= 0 {
+			group.Parent = layers[parents[i]].LayerName
+			parent := &groups[index[parents[i]]]
+			parent.Children = append(parent.Children, group.ID())
+		}
+		index[i] = len(groups)
+		groups = append(groups, group)
+	}
+	for i, object := range objects {
+		g := parents[object.layer]
+		if g < 0 {
+			continue
+		}
+		x, y := center(g)
+		objects[i].X -= x
+		objects[i].Y -= y
+		objects[i].Parent = layers[g].LayerName
+		groups[index[g]].Children = append(groups[index[g]].Children, object.ID())
+	}
+	return groups
+}
+
+// configureCollision sets up the collision object of a trigger from user data or properties, e.g.
+// "type=static group=wall mask=player,enemy shape=sphere", or a name prefix like static:wall.
+// Triggers collide with the player and have a box shape by default
+func configureCollision(e *element, data map[string]string) error {
+	c := &collision{
+		Type:  "trigger",
+		Group: "trigger",
+		Mask:  []string{"player"},
+		Shape: "box",
+	}
+	for _, t := range []string{"trigger", "static", "kinematic", "dynamic"} {
+		if name, ok := strings.CutPrefix(e.Name, t+":"); ok {
+			e.Name = name
+			c.Type = t
+			if t != "trigger" {
+				c.Group = t
+			}
+		}
+	}
+	if v, ok := data["type"]; ok {
+		c.Type = v
+	}
+	if v, ok := data["group"]; ok {
+		c.Group = v
+	}
+	if v, ok := data["mask"]; ok {
+		c.Mask = strings.Split(v, ",")
+	}
+	if v, ok := data["shape"]; ok {
+		c.Shape = v
+	}
+	switch c.Type {
+	case "trigger", "static", "kinematic", "dynamic":
+		c.Type = "COLLISION_OBJECT_TYPE_" + strings.ToUpper(c.Type)
+	default:
+		return fmt.Errorf("unknown collision object type %s", c.Type)
+	}
+	w, h := float64(e.W), float64(e.H)
+	switch c.Shape {
+	case "box":
+		c.Data = []float64{w, h, 10}
+	case "sphere":
+		c.Data = []float64{min(w, h)}
+	case "capsule":
+		log.Printf("WARNING: capsule shapes are only supported by defold's 3D physics, used by %s", e.Name)
+		c.Data = []float64{w, max(2*h-2*w, 0)}
+	default:
+		return fmt.Errorf("unknown shape %s", c.Shape)
+	}
+	c.Shape = "TYPE_" + strings.ToUpper(c.Shape)
+	e.Collision = c
+	return nil
+}
+
+// pathWaypoint parses names like path:guard1:3, for the third waypoint of the path guard1
+func pathWaypoint(name string) (path string, index int, ok bool) {
+	rest, ok := strings.CutPrefix(name, "path:")
+	if !ok {
+		return "", 0, false
+	}
+	path, number, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(number)
+	if err != nil {
+		return "", 0, false
+	}
+	return path, index, true
+}
+
+// pathPixels returns the visible pixels of a cel as waypoints. They're ordered from the leftmost pixel,
+// each followed by the nearest remaining one, so a path can be drawn without numbering its points
+func pathPixels(cel asefile.AsepriteCelChunk2005, height int16) []waypoint {
+	var points []waypoint
+	w := int(cel.WidthInPix)
+	for i := 0; i*4+3 < len(cel.RawCelData); i++ {
+		if cel.RawCelData[i*4+3] == 0 {
+			continue
+		}
+		// the waypoint is the center of the pixel
+		points = append(points, waypoint{
+			X: float64(int(cel.X)+i%w) + 0.5,
+			// y coordinates are reversed in defold
+			Y: float64(int(height)-int(cel.Y)-i/w) - 0.5,
+		})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].X < points[j].X })
+	for i := range points {
+		points[i].Index = i + 1
+		nearest := i + 1
+		for j := i + 2; j < len(points); j++ {
+			if distance(points[i], points[j]) < distance(points[i], points[nearest]) {
+				nearest = j
+			}
+		}
+		if nearest < len(points) {
+			points[i+1], points[nearest] = points[nearest], points[i+1]
+		}
+	}
+	return points
+}
+
+// distance is the squared distance between two waypoints
+func distance(a, b waypoint) float64 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
+
+// sliceKeys returns the visible keys of slice that apply to any frame between from and to.
+// A key applies from its frame number until the frame of the next key
+func sliceKeys(slice asefile.AsepriteSliceChunk2022, from, to int) []asefile.AsepriteSliceChunk2022Data {
+	var keys []asefile.AsepriteSliceChunk2022Data
+	for i, key := range slice.SliceKeysData {
+		start, end := int(key.FrameNumber), to
+		if i+1 < len(slice.SliceKeysData) {
+			end = int(slice.SliceKeysData[i+1].FrameNumber) - 1
+		}
+		if start > to || end < from || key.SliceWidth == 0 {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// decodeTile separates the tile ID from the flip flags stored in its high bits,
+// using the bitmasks from the tilemap cel header
+func decodeTile(cel asefile.AsepriteCelChunk2005, value uint32) tile {
+	// Files written before aseprite 1.3 have no bitmasks, the whole value is the ID
+	if cel.BitMaskForTileID == 0 {
+		return tile{Index: int(value)}
+	}
+	var (
+		xFlip    = value&cel.BitMaskForXFlip != 0
+		yFlip    = value&cel.BitMaskForYFlip != 0
+		diagonal = value&cel.BitMaskFor90CWRot != 0
+	)
+	return flippedTile(int(value&cel.BitMaskForTileID), xFlip, yFlip, diagonal)
+}
+
+// flippedTile converts flip flags to defold's. Like tiled, aseprite applies the diagonal flip
+// (swapping x and y) before the x and y flips, which is the same as a 90 degree clockwise
+// rotation followed by a horizontal flip
+func flippedTile(index int, xFlip, yFlip, diagonal bool) tile {
+	t := tile{Index: index}
+	if xFlip != diagonal {
+		t.HFlip = 1
+	}
+	if yFlip {
+		t.VFlip = 1
+	}
+	if diagonal {
+		t.Rotate90 = 1
+	}
+	return t
+}
+
+// assignGroup puts a tile painted on a collision layer in a collision group. The group is set with
+// group=<group> in the user data of the tile or else of its tileset, and defaults to the group of the layer
+func (t *tilesource) assignGroup(index int, layerGroup string) {
+	group := layerGroup
+	if v, ok := tileData(t, index)["group"]; ok {
+		group = v
+	}
+	if existing, ok := t.groups[index]; ok && existing != group {
+		log.Printf("WARNING: tile %d of %s is in collision groups %s and %s, using %s", index, t.Name, existing, group, existing)
+		return
+	}
+	t.groups[index] = group
+}
+
+// addCollision derives a convex hull for every tile that has been assigned a collision group,
+// from the tile alpha or from mask if the tileset has a matching collision tileset
+func (t *tilesource) addCollision(mask *image.RGBA, maskImage string) {
+	t.Collision = t.Image
+	if mask != nil {
+		t.Collision = maskImage
+	} else {
+		mask = t.img
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < t.NumTiles; i++ {
+		h := hull{Index: len(t.HullPoints) / 2}
+		if group, ok := t.groups[i]; ok {
+			points := convexHull(mask, t.tileRect(i))
+			h.Count = len(points) / 2
+			h.Group = group
+			t.HullPoints = append(t.HullPoints, points...)
+			if !seen[group] {
+				seen[group] = true
+				t.CollisionGroups = append(t.CollisionGroups, group)
+			}
+		}
+		t.Hulls = append(t.Hulls, h)
+	}
+	sort.Strings(t.CollisionGroups)
+}
+
+// tileRect is where tile i is in the tilesource image, laid out in rows of Columns tiles
+func (t *tilesource) tileRect(i int) image.Rectangle {
+	columns := max(t.Columns, 1)
+	x := t.Margin + (i%columns)*(int(t.TileWidth)+t.Spacing)
+	y := t.Margin + (i/columns)*(int(t.TileHeight)+t.Spacing)
+	return image.Rect(x, y, x+int(t.TileWidth), y+int(t.TileHeight))
+}
+
+// layerCost reads the walking cost of a tilemap layer from cost=<n> in its user data
+func layerCost(data map[string]string) (int, error) {
+	value, ok := data["cost"]
+	if !ok {
+		return 0, nil
+	}
+	cost, err := strconv.Atoi(value)
+	// 0 would block the cell, which is what .collision layers are for
+	if err != nil || cost < 1 {
+		return 0, fmt.Errorf("invalid cost %q, expected a whole number of at least 1", value)
+	}
+	return cost, nil
+}
+
+// walkGrid is the pathfinding grid of a level, with a cost per tilemap cell from the bottom row up.
+// Cells cost 1 unless a layer with a cost has a tile there, and 0 means the cell can't be walked over
+type walkGrid struct {
+	Width, Height         int
+	TileWidth, TileHeight int
+	Cells                 gridCells
+	// Values are the cells of every value layer by id, in the same order as Cells
+	Values map[string]gridCells
+}
+
+// gridCells are the values of every row of a grid, unless they're run-length encoded as pairs of count and value in Runs
+type gridCells struct {
+	Rows [][]int
+	Runs []int
+}
+
+// valueLayer is a layer of values per cell, e.g. an LDtk IntGrid layer, which is exported in the grid of its level
+type valueLayer struct {
+	ID string
+	// Cells are the values from the bottom row up, 0 for empty cells
+	Cells []int
+	// solid layers block the cells that aren't empty
+	solid bool
+}
+
+// encodeCells encodes cells with run-length encoding if that makes them smaller
+func encodeCells(cells []int, width int) gridCells {
+	var runs []int
+	for i, value := range cells {
+		if i > 0 && value == cells[i-1] {
+			runs[len(runs)-2]++
+		} else {
+			runs = append(runs, 1, value)
+		}
+	}
+	if len(runs) < len(cells) {
+		return gridCells{Runs: runs}
+	}
+	var encoded gridCells
+	for y := 0; y*width < len(cells); y++ {
+		encoded.Rows = append(encoded.Rows, cells[y*width:(y+1)*width])
+	}
+	return encoded
+}
+
+// newWalkGrid builds the grid of a level from its solid and cost layers, if it has any.
+// Layers higher up override the cost of the ones below, and solid layers block cells regardless
+func newWalkGrid(lvl level, meta levelMeta) (walkGrid, bool) {
+	used := len(lvl.Values) > 0
+	for _, layer := range lvl.Layers {
+		used = used || layer.solid || layer.cost > 0
+	}
+	if !used {
+		return walkGrid{}, false
+	}
+	grid := walkGrid{
+		Width:      (meta.Width + meta.TileWidth - 1) / meta.TileWidth,
+		Height:     (meta.Height + meta.TileHeight - 1) / meta.TileHeight,
+		TileWidth:  meta.TileWidth,
+		TileHeight: meta.TileHeight,
+	}
+	cells := make([]int, grid.Width*grid.Height)
+	for i := range cells {
+		cells[i] = 1
+	}
+	set := func(layer *tilemapLayer, cost int) {
+		for _, t := range layer.Tiles {
+			x, y := int(t.X), int(t.Y)
+			if x >= 0 && y >= 0 && x < grid.Width && y < grid.Height {
+				cells[y*grid.Width+x] = cost
+			}
+		}
+	}
+	for _, layer := range lvl.Layers {
+		if layer.cost > 0 && !layer.solid {
+			set(layer, layer.cost)
+		}
+	}
+	for _, layer := range lvl.Layers {
+		if layer.solid {
+			set(layer, 0)
+		}
+	}
+	for _, layer := range lvl.Values {
+		for i, value := range layer.Cells {
+			if layer.solid && value != 0 && i < len(cells) {
+				cells[i] = 0
+			}
+		}
+		if grid.Values == nil {
+			grid.Values = make(map[string]gridCells)
+		}
+		grid.Values[layer.ID] = encodeCells(layer.Cells, grid.Width)
+	}
+	grid.Cells = encodeCells(cells, grid.Width)
+	return grid, true
+}
+
+// collisionLayer returns the collision group of a tilemap layer named e.g. solid.collision, or "" if it isn't one.
+// The group can be changed with group=<group> in the layer user data. If the layer is merged, see mergeTiles,
+// body is the static collision object for it, which collides with the player unless mask=<groups> says otherwise
+func (a asepriteImporter) collisionLayer(name string, data map[string]string) (group string, body *collision, err error) {
+	group, ok := strings.CutSuffix(name, ".collision")
+	if !ok {
+		return "", nil, nil
+	}
+	if v, ok := data["group"]; ok {
+		group = v
+	}
+	merge := a.mergeCollision
+	if v, ok := data["merge"]; ok {
+		if merge, err = strconv.ParseBool(v); err != nil {
+			return "", nil, fmt.Errorf("invalid merge %q, expected true or false", v)
+		}
+	}
+	if !merge {
+		return group, nil, nil
+	}
+	body = &collision{Type: "COLLISION_OBJECT_TYPE_STATIC", Group: group, Mask: []string{"player"}}
+	if v, ok := data["mask"]; ok {
+		body.Mask = strings.Split(v, ",")
+	}
+	return group, body, nil
+}
+
+// solidBody is a static collision object with a box per rectangle of tiles of a collision layer
+type solidBody struct {
+	ID string
+	*collision
+	Boxes []box
+}
+
+// box is a rectangle centered on X and Y. Defold box shapes are sized by their half extents,
+// and Index is where those are in the data of the collision object
+type box struct {
+	Index        int
+	X, Y         float64
+	HalfW, HalfH float64
+}
+
+// mergeTiles greedily merges the tiles of a layer into as few rectangles as it can find,
+// by growing each rectangle as wide and then as tall as possible from its bottom left tile
+func mergeTiles(layer *tilemapLayer, source *tilesource) solidBody {
+	body := solidBody{ID: strings.ReplaceAll(layer.ID, ".", "_"), collision: layer.body}
+	if len(layer.Tiles) == 0 {
+		return body
+	}
+	minX, minY, maxX, maxY := layer.Tiles[0].X, layer.Tiles[0].Y, layer.Tiles[0].X, layer.Tiles[0].Y
+	for _, t := range layer.Tiles {
+		minX, minY, maxX, maxY = min(minX, t.X), min(minY, t.Y), max(maxX, t.X), max(maxY, t.Y)
+	}
+	w, h := int(maxX-minX)+1, int(maxY-minY)+1
+	solid := make([]bool, w*h)
+	for _, t := range layer.Tiles {
+		solid[int(t.Y-minY)*w+int(t.X-minX)] = true
+	}
+	tw, th := float64(source.TileWidth), float64(source.TileHeight)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !solid[y*w+x] {
+				continue
+			}
+			right := x + 1
+			for right < w && solid[y*w+right] {
+				right++
+			}
+			top := y + 1
+			for ; top < h; top++ {
+				full := true
+				for i := x; i < right && full; i++ {
+					full = solid[top*w+i]
+				}
+				if !full {
+					break
+				}
+			}
+			for j := y; j < top; j++ {
+				for i := x; i < right; i++ {
+					solid[j*w+i] = false
+				}
+			}
+			body.Boxes = append(body.Boxes, box{
+				Index: 3 * len(body.Boxes),
+				X:     (float64(int(minX)+x) + float64(right-x)/2) * tw,
+				Y:     (float64(int(minY)+y) + float64(top-y)/2) * th,
+				HalfW: float64(right-x) * tw / 2,
+				HalfH: float64(top-y) * th / 2,
+			})
+		}
+	}
+	return body
+}
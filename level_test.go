@@ -0,0 +1,219 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pranavraja/asefile"
+)
+
+func TestDecodeTile(t *testing.T) {
+	const (
+		xFlip    = 0x20000000
+		yFlip    = 0x40000000
+		diagonal = 0x80000000
+	)
+	cel := asefile.AsepriteCelChunk2005{
+		BitMaskForTileID:  0x1fffffff,
+		BitMaskForXFlip:   xFlip,
+		BitMaskForYFlip:   yFlip,
+		BitMaskFor90CWRot: diagonal,
+	}
+	tests := []struct {
+		name  string
+		cel   asefile.AsepriteCelChunk2005
+		value uint32
+		want  tile
+	}{
+		{"no bitmasks", asefile.AsepriteCelChunk2005{}, 5 | xFlip, tile{Index: 5 | xFlip}},
+		{"plain", cel, 5, tile{Index: 5}},
+		{"x flip", cel, 5 | xFlip, tile{Index: 5, HFlip: 1}},
+		{"y flip", cel, 5 | yFlip, tile{Index: 5, VFlip: 1}},
+		{"x and y flip", cel, 5 | xFlip | yFlip, tile{Index: 5, HFlip: 1, VFlip: 1}},
+		{"diagonal", cel, 5 | diagonal, tile{Index: 5, HFlip: 1, Rotate90: 1}},
+		{"diagonal and x flip", cel, 5 | diagonal | xFlip, tile{Index: 5, Rotate90: 1}},
+		{"diagonal and y flip", cel, 5 | diagonal | yFlip, tile{Index: 5, HFlip: 1, VFlip: 1, Rotate90: 1}},
+		{"all flags", cel, 5 | diagonal | xFlip | yFlip, tile{Index: 5, VFlip: 1, Rotate90: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := decodeTile(test.cel, test.value); got != test.want {
+				t.Errorf("decodeTile(%#x) = %+v, want %+v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+// tilesOf returns a tile for every # in rows, which are drawn top row first like in the editor
+func tilesOf(rows ...string) []tile {
+	var tiles []tile
+	for i, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				tiles = append(tiles, tile{X: int16(x), Y: int16(len(rows) - 1 - i)})
+			}
+		}
+	}
+	return tiles
+}
+
+func TestMergeTiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiles []tile
+		want  []box
+	}{
+		{"empty", nil, nil},
+		{"single", tilesOf("#"), []box{{0, 8, 4, 8, 4}}},
+		{"rectangle", tilesOf(
+			"###",
+			"###",
+		), []box{{0, 24, 8, 24, 8}}},
+		{"l shape", tilesOf(
+			"#.",
+			"##",
+		), []box{{0, 16, 4, 16, 4}, {3, 8, 12, 8, 4}}},
+		{"ring", tilesOf(
+			"###",
+			"#.#",
+			"###",
+		), []box{{0, 24, 4, 24, 4}, {3, 8, 16, 8, 8}, {6, 40, 16, 8, 8}, {9, 24, 20, 8, 4}}},
+		{"separate", tilesOf(
+			"#..#",
+		), []box{{0, 8, 4, 8, 4}, {3, 56, 4, 8, 4}}},
+		{"offset", []tile{{X: -2, Y: 3}, {X: -1, Y: 3}}, []box{{0, -16, 28, 16, 4}}},
+	}
+	source := &tilesource{TileWidth: 16, TileHeight: 8}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layer := &tilemapLayer{ID: "solid.collision", Tiles: test.tiles}
+			body := mergeTiles(layer, source)
+			if body.ID != "solid_collision" {
+				t.Errorf("mergeTiles id = %s, want solid_collision", body.ID)
+			}
+			if !reflect.DeepEqual(body.Boxes, test.want) {
+				t.Errorf("mergeTiles boxes = %v, want %v", body.Boxes, test.want)
+			}
+		})
+	}
+}
+
+func TestLevelRooms(t *testing.T) {
+	frames := func(n int, tags ...asefile.AsepriteTagsChunk2018Tag) asefile.AsepriteFile {
+		file := asefile.AsepriteFile{Frames: make([]asefile.AsepriteFrame, n)}
+		file.Frames[0].Tags.Tags = tags
+		return file
+	}
+	walk := asefile.AsepriteTagsChunk2018Tag{TagName: "walk", FromFrame: 0, ToFrame: 1}
+	cave := asefile.AsepriteTagsChunk2018Tag{TagName: "cave", FromFrame: 2, ToFrame: 2}
+	tests := []struct {
+		name string
+		file asefile.AsepriteFile
+		want []room
+	}{
+		{"town", frames(3), []room{{"town", 0, 2}}},
+		// tags are also used for animations, so they only make rooms in .rooms files
+		{"town", frames(3, walk), []room{{"town", 0, 2}}},
+		{"dungeon.rooms", frames(3, walk, cave), []room{{"dungeon_walk", 0, 1}, {"dungeon_cave", 2, 2}}},
+		{"dungeon.rooms", frames(2), []room{{"dungeon_0", 0, 0}, {"dungeon_1", 1, 1}}},
+	}
+	for _, test := range tests {
+		if got := levelRooms(test.name, test.file); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("levelRooms(%s) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLayerDepths(t *testing.T) {
+	layers := []asefile.AsepriteLayerChunk2004{{LayerName: "ground"}, {LayerName: "clouds@0.9"}, {LayerName: "me@home"}, {LayerName: "sky"}}
+	data := []map[string]string{{}, {}, {}, {"z": "-0.5"}}
+	a := asepriteImporter{zMin: -1, zMax: 1}
+	z, err := a.layerDepths(layers, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{-0.75, 0.9, 0.25, -0.5}; !reflect.DeepEqual(z, want) {
+		t.Errorf("layerDepths = %v, want %v", z, want)
+	}
+	if layers[1].LayerName != "clouds@0.9" {
+		t.Errorf("layerDepths renamed clouds@0.9 to %s", layers[1].LayerName)
+	}
+	trimDepths(layers)
+	var names []string
+	for _, layer := range layers {
+		names = append(names, layer.LayerName)
+	}
+	if want := []string{"ground", "clouds", "me@home", "sky"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("trimDepths = %v, want %v", names, want)
+	}
+	if _, err := a.layerDepths(layers[:1], []map[string]string{{"z": "high"}}); err == nil {
+		t.Error("layerDepths accepted z=high")
+	}
+}
+
+func TestAssignGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		tileset map[string]string
+		tile    map[string]string
+		want    string
+	}{
+		{"layer", nil, nil, "solid"},
+		{"tileset", map[string]string{"group": "ground"}, nil, "ground"},
+		{"tile", map[string]string{"group": "ground"}, map[string]string{"group": "spikes"}, "spikes"},
+		{"other tile data", nil, map[string]string{"health": "3"}, "solid"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := &tilesource{data: test.tileset, tiles: map[int]map[string]string{2: test.tile}, groups: make(map[int]string)}
+			source.assignGroup(2, "solid")
+			// the first group a tile is assigned wins
+			source.assignGroup(2, "wall")
+			if got := source.groups[2]; got != test.want {
+				t.Errorf("assignGroup = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEncodeCells(t *testing.T) {
+	tests := []struct {
+		name  string
+		cells []int
+		want  gridCells
+	}{
+		{"runs", []int{0, 0, 0, 1, 1, 1}, gridCells{Runs: []int{3, 0, 3, 1}}},
+		{"rows", []int{0, 1, 2, 3, 4, 5}, gridCells{Rows: [][]int{{0, 1, 2}, {3, 4, 5}}}},
+		// as many runs as cells isn't smaller
+		{"same size", []int{0, 0, 1, 1, 2, 3}, gridCells{Rows: [][]int{{0, 0, 1}, {1, 2, 3}}}},
+	}
+	for _, test := range tests {
+		if got := encodeCells(test.cells, 3); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("encodeCells(%s) = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLayerCost(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+		err   bool
+	}{
+		{"", 0, false},
+		{"3", 3, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"fast", 0, true},
+	}
+	for _, test := range tests {
+		data := map[string]string{}
+		if test.value != "" {
+			data["cost"] = test.value
+		}
+		cost, err := layerCost(data)
+		if cost != test.want || (err != nil) != test.err {
+			t.Errorf("layerCost(%q) = %d, %v", test.value, cost, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import "text/template"
+
+var levelsCollectionTemplate = template.Must(template.New("").Parse(`
+name: "levels"
+scale_along_z: 0
+embedded_instances {
+  id: "levels"
+  data: ""
+  {{- range . }}
+  "embedded_components {\n"
+  "  id: \"{{ . }}\"\n"
+  "  type: \"collectionproxy\"\n"
+  "  data: \"collection: \\\"/import/{{ . }}.collection\\\"\\n"
+  "exclude: false\\n"
+  "\"\n"
+  "  position {\n"
+  "    x: 0.0\n"
+  "    y: 0.0\n"
+  "    z: 0.0\n"
+  "  }\n"
+  "  rotation {\n"
+  "    x: 0.0\n"
+  "    y: 0.0\n"
+  "    z: 0.0\n"
+  "    w: 1.0\n"
+  "  }\n"
+  "}\n"
+  {{- end }}
+  position {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+  }
+  rotation {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+    w: 1.0
+  }
+  scale3 {
+    x: 1.0
+    y: 1.0
+    z: 1.0
+  }
+}
+`))
+
+var levelsTemplate = template.Must(template.New("").Parse(`
+local levels = {}
+-- every level, in the order of the files they were imported from
+levels.order = {
+{{- range . }}
+	{{ printf "%q" . }},
+{{- end }}
+}
+-- the path of the levels game object of levels.collection, which holds the collection proxies.
+-- This is where it is when levels.collection is added to the bootstrap collection with the id levels
+levels.root = "/levels/levels"
+-- the ids of the collection proxies of the levels
+levels.proxies = {
+{{- range . }}
+	[{{ printf "%q" . }}] = {{ printf "%q" . }},
+{{- end }}
+}
+levels.index = {}
+for i, name in ipairs(levels.order) do
+	levels.index[name] = i
+end
+
+-- url returns the url of the collection proxy of a level, in the collection of the caller
+function levels.url(name)
+	return msg.url(nil, levels.root, levels.proxies[name])
+end
+
+-- next returns the level after name, or nil for the last level and unknown levels
+function levels.next(name)
+	local index = levels.index[name]
+	return index and levels.order[index + 1]
+end
+return levels
+`))
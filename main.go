@@ -27,8 +27,10 @@ func (i importer) Import() error {
 			return nil
 		}
 		switch filepath.Ext(path) {
-		case ".aseprite":
+		case ".aseprite", ".tmx", ".ldtk":
 			asepriteFiles = append(asepriteFiles, path)
+		case ".tsx":
+			// tiled tilesets are read by the maps using them
 		case ".ink":
 			inkFiles = append(inkFiles, path)
 		case ".csv":
@@ -54,11 +56,20 @@ func (i importer) Import() error {
 }
 
 func main() {
-	var output string
+	var (
+		output         string
+		zMin, zMax     float64
+		mergeCollision bool
+		autoAnchor     bool
+	)
 	flag.StringVar(&output, "output", "import", "Folder to output to")
+	flag.Float64Var(&zMin, "zmin", -1, "z of the bottom layer in levels")
+	flag.Float64Var(&zMax, "zmax", 1, "z of the top layer in levels")
+	flag.BoolVar(&mergeCollision, "mergecollision", false, "merge the tiles of level collision layers into static boxes")
+	flag.BoolVar(&autoAnchor, "autoanchor", false, "anchor UI nodes to the screen edges they're closest to")
 	flag.Parse()
 	importer := importer{root: flag.Arg(0)}
-	importer.aseprite = asepriteImporter{output}
+	importer.aseprite = asepriteImporter{outputDir: output, zMin: zMin, zMax: zMax, mergeCollision: mergeCollision, autoAnchor: autoAnchor}
 	importer.ink = inkImporter{output}
 	importer.csv = csvImporter{output}
 	if err := importer.Import(); err != nil {
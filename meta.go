@@ -0,0 +1,46 @@
+package main
+
+import "text/template"
+
+var metaTemplate = template.Must(template.New("").Parse(`
+local meta = {}
+meta.width = {{ .Width }}
+meta.height = {{ .Height }}
+{{- if .TileWidth }}
+meta.tile_width = {{ .TileWidth }}
+meta.tile_height = {{ .TileHeight }}
+{{- end }}
+meta.objects = {
+{{- range $name, $objects := .Objects }}
+	[{{ printf "%q" $name }}] = {
+	{{- range $objects }}
+		{ x = {{ .X }}, y = {{ .Y }} },
+	{{- end }}
+	},
+{{- end }}
+}
+meta.slices = {
+{{- range $name, $slices := .Slices }}
+	[{{ printf "%q" $name }}] = {
+	{{- range $slices }}
+		{ x = {{ .X }}, y = {{ .Y }}, width = {{ .W }}, height = {{ .H }} },
+	{{- end }}
+	},
+{{- end }}
+}
+meta.paths = {
+{{- range $name, $points := .Paths }}
+	[{{ printf "%q" $name }}] = {
+	{{- range $points }}
+		{ x = {{ .X }}, y = {{ .Y }} },
+	{{- end }}
+	},
+{{- end }}
+}
+meta.layers = {
+{{- range .Layers }}
+	{{ printf "%q" . }},
+{{- end }}
+}
+return meta
+`))
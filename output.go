@@ -18,3 +18,11 @@ func (a asepriteImporter) render(filename string, tmpl *template.Template, data
 	}
 	return a.writeFile(filename, buf)
 }
+
+// renderIfMissing renders a file that's meant to be edited by hand, unless it already exists
+func (a asepriteImporter) renderIfMissing(filename string, tmpl *template.Template, data any) error {
+	if _, err := os.Stat(filepath.Join(a.outputDir, filename)); err == nil {
+		return nil
+	}
+	return a.render(filename, tmpl, data)
+}
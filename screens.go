@@ -0,0 +1,86 @@
+package main
+
+import "text/template"
+
+var screensCollectionTemplate = template.Must(template.New("").Parse(`
+name: "ui"
+scale_along_z: 0
+{{- range . }}
+instances {
+  id: "{{ . }}"
+  prototype: "/import/{{ . }}.go"
+  position {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+  }
+  rotation {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+    w: 1.0
+  }
+  scale3 {
+    x: 1.0
+    y: 1.0
+    z: 1.0
+  }
+}
+{{- end }}
+embedded_instances {
+  id: "ui"
+  data: "components {\n"
+  "  id: \"script\"\n"
+  "  component: \"/import/ui.script\"\n"
+  "}\n"
+  position {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+  }
+  rotation {
+    x: 0.0
+    y: 0.0
+    z: 0.0
+    w: 1.0
+  }
+  scale3 {
+    x: 1.0
+    y: 1.0
+    z: 1.0
+  }
+}
+`))
+
+var screensScriptTemplate = template.Must(template.New("").Parse(`
+-- every screen in ui.collection, they start disabled
+local screens = {
+{{- range . }}
+	{{ printf "%q" . }},
+{{- end }}
+}
+
+-- url is relative to ui.collection, wherever it is added
+local function url(screen)
+	return screen .. "#gui"
+end
+
+function init(self)
+	for _, screen in ipairs(screens) do
+		msg.post(url(screen), "disable")
+	end
+end
+
+-- "show" enables a screen and disables the others, and "hide" disables a screen. This script is in the ui
+-- game object of ui.collection, so with ui.collection added to the bootstrap collection with the id ui, that's
+-- msg.post("/ui/ui#script", "show", { screen = "menu" })
+function on_message(self, message_id, message, sender)
+	if message_id == hash("show") then
+		for _, screen in ipairs(screens) do
+			msg.post(url(screen), screen == message.screen and "enable" or "disable")
+		end
+	elseif message_id == hash("hide") and message.screen then
+		msg.post(url(message.screen), "disable")
+	end
+end
+`))
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pranavraja/asefile"
+)
+
+// tmxMap and the types below mirror the parts of tiled's TMX and TSX formats the importer uses
+type tmxMap struct {
+	Width      int          `xml:"width,attr"`
+	Height     int          `xml:"height,attr"`
+	TileWidth  int          `xml:"tilewidth,attr"`
+	TileHeight int          `xml:"tileheight,attr"`
+	Infinite   bool         `xml:"infinite,attr"`
+	Tilesets   []tmxTileset `xml:"tileset"`
+	// Layers are in drawing order, whatever their type
+	Layers []tmxLayer `xml:",any"`
+}
+
+type tmxTileset struct {
+	FirstGID   uint32 `xml:"firstgid,attr"`
+	Source     string `xml:"source,attr"`
+	Name       string `xml:"name,attr"`
+	TileWidth  int    `xml:"tilewidth,attr"`
+	TileHeight int    `xml:"tileheight,attr"`
+	Spacing    int    `xml:"spacing,attr"`
+	Margin     int    `xml:"margin,attr"`
+	TileCount  int    `xml:"tilecount,attr"`
+	Columns    int    `xml:"columns,attr"`
+	Image      struct {
+		Source string `xml:"source,attr"`
+	} `xml:"image"`
+	Properties []tmxProperty `xml:"properties>property"`
+	// Tiles are only listed when they have properties or other data
+	Tiles []struct {
+		ID         int           `xml:"id,attr"`
+		Properties []tmxProperty `xml:"properties>property"`
+	} `xml:"tile"`
+}
+
+// tmxLayer is a layer, objectgroup, imagelayer or group, depending on XMLName
+type tmxLayer struct {
+	XMLName    xml.Name
+	Name       string        `xml:"name,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+	Data       tmxData       `xml:"data"`
+	Objects    []tmxObject   `xml:"object"`
+	// Layers are the children of a group
+	Layers []tmxLayer `xml:",any"`
+}
+
+type tmxData struct {
+	Encoding    string `xml:"encoding,attr"`
+	Compression string `xml:"compression,attr"`
+	Tiles       []struct {
+		GID uint32 `xml:"gid,attr"`
+	} `xml:"tile"`
+	Text string `xml:",chardata"`
+}
+
+type tmxObject struct {
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	GID        uint32        `xml:"gid,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+	Ellipse    *struct{}     `xml:"ellipse"`
+	Point      *struct{}     `xml:"point"`
+	Polygon    *struct{}     `xml:"polygon"`
+	Polyline   *tmxPoints    `xml:"polyline"`
+}
+
+type tmxPoints struct {
+	// Points are x,y pairs separated by spaces, relative to the object
+	Points string `xml:"points,attr"`
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	// Multiline strings are stored as text instead of in the value attribute
+	Text string `xml:",chardata"`
+}
+
+// tiled stores flip flags in the high bits of global tile IDs
+const (
+	tmxFlipX        = 0x80000000
+	tmxFlipY        = 0x40000000
+	tmxFlipDiagonal = 0x20000000
+	tmxGIDMask      = 0x0fffffff
+)
+
+func tmxProperties(properties []tmxProperty) map[string]string {
+	values := make(map[string]string)
+	for _, p := range properties {
+		values[p.Name] = p.Value
+		if p.Value == "" {
+			values[p.Name] = strings.TrimSpace(p.Text)
+		}
+	}
+	return values
+}
+
+// importTiled imports a tiled map like a level made in aseprite, see importLevel.
+// Tile layers become tilemap layers, objects with a type or on .object layers become instances,
+// and other rectangles and ellipses become triggers configured by their custom properties
+func (a asepriteImporter) importTiled(file, filename string, dataOffset int) ([]string, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var m tmxMap
+	if err := xml.Unmarshal(contents, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %s", file, err)
+	}
+	if m.Infinite {
+		return nil, fmt.Errorf("%s: infinite maps are not supported", file)
+	}
+	sources, err := a.tiledTilesets(file, filename, m)
+	if err != nil {
+		return nil, err
+	}
+	// Flatten the layers like aseprite does, so z-order and groups work the same
+	var (
+		layers    []asefile.AsepriteLayerChunk2004
+		data      []map[string]string
+		tmxLayers []tmxLayer
+	)
+	var flatten func(children []tmxLayer, level int)
+	flatten = func(children []tmxLayer, level int) {
+		for _, child := range children {
+			layer := asefile.AsepriteLayerChunk2004{LayerName: child.Name, LayerChildLevel: uint16(level)}
+			switch child.XMLName.Local {
+			case "group":
+				layer.LayerType = 1
+			case "layer":
+				layer.LayerType = 2
+			case "objectgroup", "imagelayer":
+			default:
+				continue
+			}
+			layers = append(layers, layer)
+			data = append(data, tmxProperties(child.Properties))
+			tmxLayers = append(tmxLayers, child)
+			if layer.LayerType == 1 {
+				flatten(child.Layers, level+1)
+			}
+		}
+	}
+	flatten(m.Layers, 0)
+	z, err := a.layerDepths(layers, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	trimDepths(layers)
+	var (
+		objects  []element
+		triggers []element
+		tilemap  []*tilemapLayer
+		datas    []string
+		meta     = levelMeta{
+			Width:   m.Width * m.TileWidth,
+			Height:  m.Height * m.TileHeight,
+			Objects: make(map[string][]element),
+			Slices:  make(map[string][]element),
+			Paths:   make(map[string][]waypoint),
+		}
+		// A defold tilemap can only reference one tilesource
+		tilemapSource *tilesource
+		height        = float64(meta.Height)
+	)
+	for i, layer := range tmxLayers {
+		name := layers[i].LayerName
+		switch layer.XMLName.Local {
+		case "layer":
+			gids, err := decodeTMXData(layer.Data, m.Width*m.Height)
+			if err != nil {
+				return nil, fmt.Errorf("layer %s in %s: %s", name, file, err)
+			}
+			objectName, isObject := strings.CutSuffix(name, ".object")
+			collisionGroup, body, err := a.collisionLayer(name, data[i])
+			if err != nil {
+				return nil, fmt.Errorf("layer %s in %s: %s", name, file, err)
+			}
+			var tiles tilemapLayer
+			for j, gid := range gids {
+				if gid&tmxGIDMask == 0 {
+					continue
+				}
+				source, firstGID := tiledSource(m, sources, gid&tmxGIDMask)
+				if source == nil {
+					return nil, fmt.Errorf("layer %s in %s uses unknown tile %d", name, file, gid&tmxGIDMask)
+				}
+				col, row := j%m.Width, j/m.Width
+				if isObject {
+					objects = append(objects, element{
+						X:          int16(col*m.TileWidth + m.TileWidth/2),
+						Y:          int16(height) - int16(row*m.TileHeight+m.TileHeight/2),
+						Name:       objectName,
+						Index:      len(objects) + 1,
+						Z:          z[i],
+						Properties: componentProperties(mergeUserData(data[i], tileData(source, int(gid&tmxGIDMask-firstGID))), tileKeys...),
+						layer:      i,
+					})
+					continue
+				}
+				if tilemapSource != nil && tilemapSource != source {
+					return nil, fmt.Errorf("level %s uses more than one tileset (%s and %s), please split it into separate files", filename, tilemapSource.Name, source.Name)
+				}
+				tilemapSource = source
+				t := flippedTile(int(gid&tmxGIDMask-firstGID), gid&tmxFlipX != 0, gid&tmxFlipY != 0, gid&tmxFlipDiagonal != 0)
+				t.X = int16(col)
+				t.Y = int16(m.Height - row - 1)
+				tiles.Tiles = append(tiles.Tiles, t)
+				// merged layers collide through the rectangles of their body instead
+				if collisionGroup != "" && body == nil {
+					source.assignGroup(t.Index, collisionGroup)
+				}
+			}
+			if len(tiles.Tiles) > 0 {
+				tiles.ID, tiles.Z = name, z[i]
+				tiles.solid, tiles.body, tiles.hulls = collisionGroup != "", body, collisionGroup != "" && body == nil
+				if tiles.cost, err = layerCost(data[i]); err != nil {
+					return nil, fmt.Errorf("layer %s in %s: %s", name, file, err)
+				}
+				tilemap = append(tilemap, &tiles)
+			}
+		case "objectgroup":
+			layerObject, onObjectLayer := strings.CutSuffix(name, ".object")
+			for _, object := range layer.Objects {
+				properties := tmxProperties(object.Properties)
+				// tile objects inherit the properties of their tile
+				if object.GID != 0 {
+					source, firstGID := tiledSource(m, sources, object.GID&tmxGIDMask)
+					properties = mergeUserData(tileData(source, int(object.GID&tmxGIDMask-firstGID)), properties)
+				}
+				centerX, centerY := object.X+object.Width/2, object.Y+object.Height/2
+				// tile objects are positioned by their bottom left corner
+				if object.GID != 0 {
+					centerY = object.Y - object.Height/2
+				}
+				// Objects named e.g. path:guard1:3 are the third waypoint of the path guard1
+				if path, index, ok := pathWaypoint(object.Name); ok {
+					// y coordinates are reversed in defold
+					meta.Paths[path] = append(meta.Paths[path], waypoint{Index: index, X: centerX, Y: height - centerY})
+					continue
+				}
+				// A polyline is a whole path
+				if object.Polyline != nil {
+					for j, point := range strings.Fields(object.Polyline.Points) {
+						var x, y float64
+						if _, err := fmt.Sscanf(point, "%g,%g", &x, &y); err != nil {
+							return nil, fmt.Errorf("polyline %s in %s: invalid point %s", object.Name, file, point)
+						}
+						meta.Paths[object.Name] = append(meta.Paths[object.Name], waypoint{
+							Index: j + 1,
+							X:     object.X + x,
+							// y coordinates are reversed in defold
+							Y: height - object.Y - y,
+						})
+					}
+					continue
+				}
+				prototype := object.Type
+				if prototype == "" {
+					prototype = object.Class
+				}
+				if prototype == "" && onObjectLayer {
+					prototype = layerObject
+				}
+				if prototype == "" && (object.GID != 0 || object.Point != nil) {
+					prototype = object.Name
+				}
+				if prototype != "" {
+					keys := layerKeys
+					if object.GID != 0 {
+						keys = tileKeys
+					}
+					objects = append(objects, element{
+						X: int16(centerX),
+						// y coordinates are reversed in defold
+						Y:          int16(height - centerY),
+						Name:       prototype,
+						Index:      len(objects) + 1,
+						Z:          z[i],
+						Properties: componentProperties(properties, keys...),
+						layer:      i,
+					})
+					continue
+				}
+				if object.Polygon != nil {
+					log.Printf("WARNING: skipping polygon %s in %s, only rectangles and ellipses become triggers", object.Name, file)
+					continue
+				}
+				trigger := element{
+					Index: dataOffset + 1,
+					Group: filename,
+					Name:  object.Name,
+					X:     int16(centerX),
+					// y coordinates are reversed in defold
+					Y: int16(height - centerY),
+					// For some reason box2d shapes end up twice the width you specify
+					W: int(object.Width / 2),
+					H: int(object.Height / 2),
+				}
+				if _, ok := properties["shape"]; !ok && object.Ellipse != nil {
+					properties["shape"] = "sphere"
+				}
+				if err := configureCollision(&trigger, properties); err != nil {
+					return nil, fmt.Errorf("object %s in %s: %s", object.Name, file, err)
+				}
+				triggers = append(triggers, trigger)
+				meta.Slices[trigger.Name] = append(meta.Slices[trigger.Name], element{
+					X: int16(object.X),
+					// y coordinates are reversed in defold
+					Y: int16(height - object.Y - object.Height),
+					W: int(object.Width),
+					H: int(object.Height),
+				})
+				dataOffset++
+				datas = append(datas, trigger.Name)
+			}
+		case "imagelayer":
+			log.Printf("WARNING: skipping image layer %s in %s, image layers are not supported", name, file)
+		}
+	}
+	for _, source := range sources {
+		if len(source.groups) > 0 {
+			source.addCollision(nil, "")
+		}
+		if err := a.render(source.Name+".tilesource", tilesourceTemplate, source); err != nil {
+			return nil, err
+		}
+	}
+	lvl := level{
+		Filename: filename,
+		Objects:  objects,
+		Triggers: triggers,
+		Layers:   tilemap,
+	}
+	return datas, a.writeLevel(lvl, tilemapSource, layers, meta)
+}
+
+// tiledTilesets copies the tileset images of a map next to the other imported images,
+// loading external TSX tilesets relative to the map. They are returned in the same order as in the map
+func (a asepriteImporter) tiledTilesets(file, filename string, m tmxMap) ([]*tilesource, error) {
+	var sources []*tilesource
+	for _, tileset := range m.Tilesets {
+		dir := filepath.Dir(file)
+		if tileset.Source != "" {
+			tsx := filepath.Join(dir, tileset.Source)
+			contents, err := os.ReadFile(tsx)
+			if err != nil {
+				return nil, err
+			}
+			firstGID := tileset.FirstGID
+			if err := xml.Unmarshal(contents, &tileset); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %s", tsx, err)
+			}
+			tileset.FirstGID = firstGID
+			dir = filepath.Dir(tsx)
+		}
+		if tileset.Image.Source == "" {
+			return nil, fmt.Errorf("tileset %s in %s: collections of images are not supported, please use a single image", tileset.Name, file)
+		}
+		f, err := os.Open(filepath.Join(dir, tileset.Image.Source))
+		if err != nil {
+			return nil, err
+		}
+		decoded, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("tileset %s in %s: %s", tileset.Name, file, err)
+		}
+		img := image.NewRGBA(decoded.Bounds())
+		draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+		source := &tilesource{
+			Name:       fmt.Sprintf("%s_%s", filename, tileset.Name),
+			Image:      fmt.Sprintf("%s_tiles_%s", filename, tileset.Name),
+			TileWidth:  uint16(tileset.TileWidth),
+			TileHeight: uint16(tileset.TileHeight),
+			NumTiles:   tileset.TileCount,
+			Columns:    tileset.Columns,
+			Margin:     tileset.Margin,
+			Spacing:    tileset.Spacing,
+			img:        img,
+			data:       tmxProperties(tileset.Properties),
+			tiles:      make(map[int]map[string]string),
+			groups:     make(map[int]string),
+		}
+		for _, t := range tileset.Tiles {
+			source.tiles[t.ID] = tmxProperties(t.Properties)
+		}
+		if err := a.writeImage(fmt.Sprintf("img/%s.png", source.Image), img); err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// tiledSource finds the tileset a global tile ID belongs to, the one with the largest first ID below it
+func tiledSource(m tmxMap, sources []*tilesource, gid uint32) (*tilesource, uint32) {
+	var (
+		found    *tilesource
+		firstGID uint32
+	)
+	for i, tileset := range m.Tilesets {
+		if tileset.FirstGID <= gid && tileset.FirstGID >= firstGID {
+			found, firstGID = sources[i], tileset.FirstGID
+		}
+	}
+	return found, firstGID
+}
+
+// decodeTMXData reads the global tile IDs of a layer, in any of the encodings tiled supports except zstd
+func decodeTMXData(data tmxData, count int) ([]uint32, error) {
+	switch data.Encoding {
+	case "":
+		var gids []uint32
+		for _, t := range data.Tiles {
+			gids = append(gids, t.GID)
+		}
+		return gids, nil
+	case "csv":
+		var gids []uint32
+		for _, field := range strings.Split(data.Text, ",") {
+			gid, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tile data: %s", err)
+			}
+			gids = append(gids, uint32(gid))
+		}
+		return gids, nil
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data.Text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tile data: %s", err)
+		}
+		var r io.Reader = bytes.NewReader(raw)
+		switch data.Compression {
+		case "":
+		case "zlib":
+			if r, err = zlib.NewReader(r); err != nil {
+				return nil, err
+			}
+		case "gzip":
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported compression %s, please use zlib or gzip", data.Compression)
+		}
+		gids := make([]uint32, count)
+		if err := binary.Read(r, binary.LittleEndian, gids); err != nil {
+			return nil, fmt.Errorf("failed to read tile data: %s", err)
+		}
+		return gids, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %s", data.Encoding)
+	}
+}
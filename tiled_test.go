@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeTMXData(t *testing.T) {
+	gids := []uint32{1, 0, 2, 0x80000003}
+	raw := new(bytes.Buffer)
+	binary.Write(raw, binary.LittleEndian, gids)
+	compress := func(w io.WriteCloser, buf *bytes.Buffer) string {
+		w.Write(raw.Bytes())
+		w.Close()
+		return base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+	zlibbed, gzipped := new(bytes.Buffer), new(bytes.Buffer)
+	tests := []struct {
+		name    string
+		data    string
+		want    []uint32
+		wantErr bool
+	}{
+		{"xml", `<data><tile gid="1"/><tile/><tile gid="2"/><tile gid="2147483651"/></data>`, gids, false},
+		{"csv", `<data encoding="csv">
+1,0,2,
+2147483651
+</data>`, gids, false},
+		{"invalid csv", `<data encoding="csv">1,x,2,3</data>`, nil, true},
+		{"base64", `<data encoding="base64">
+   ` + base64.StdEncoding.EncodeToString(raw.Bytes()) + `
+</data>`, gids, false},
+		{"zlib", `<data encoding="base64" compression="zlib">` + compress(zlib.NewWriter(zlibbed), zlibbed) + `</data>`, gids, false},
+		{"gzip", `<data encoding="base64" compression="gzip">` + compress(gzip.NewWriter(gzipped), gzipped) + `</data>`, gids, false},
+		{"zstd", `<data encoding="base64" compression="zstd">KLUv/QBYAQAAAAAA</data>`, nil, true},
+		{"too short", `<data encoding="base64">AQAAAAAAAAA=</data>`, nil, true},
+		{"unknown encoding", `<data encoding="hex">01</data>`, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data tmxData
+			if err := xml.Unmarshal([]byte(test.data), &data); err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodeTMXData(data, len(gids))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("decodeTMXData error = %v, want error %t", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeTMXData = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -3,22 +3,24 @@ package main
 import "text/template"
 
 var tilemapTemplate = template.Must(template.New("").Parse(`
-tile_set: "/game/{{ .Filename }}.tilesource"
+tile_set: "/import/{{ .Tileset }}.tilesource"
+{{- range .Layers }}
 layers {
-  id: "background"
-  z: 0.0
-  is_visible: 1
+  id: "{{ .ID }}"
+  z: {{ printf "%.3f" .Z }}
+  is_visible: {{ if .Hidden }}0{{ else }}1{{ end }}
   {{- range .Tiles }}
   cell {
     x: {{ .X }}
     y: {{ .Y }}
     tile: {{ .Index }}
-    h_flip: 0
-    v_flip: 0
-    rotate90: 0
+    h_flip: {{ .HFlip }}
+    v_flip: {{ .VFlip }}
+    rotate90: {{ .Rotate90 }}
   }
   {{- end }}
 }
+{{- end }}
 material: "/builtins/materials/tile_map.material"
 blend_mode: BLEND_MODE_ALPHA
 `))
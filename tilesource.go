@@ -0,0 +1,35 @@
+package main
+
+import "text/template"
+
+var tilesourceTemplate = template.Must(template.New("").Parse(`
+image: "/import/img/{{ .Image }}.png"
+tile_width: {{ .TileWidth }}
+tile_height: {{ .TileHeight }}
+tile_margin: {{ .Margin }}
+tile_spacing: {{ .Spacing }}
+{{- if .Collision }}
+collision: "/import/img/{{ .Collision }}.png"
+{{- else }}
+collision: ""
+{{- end }}
+material_tag: "tile"
+{{- range .Hulls }}
+convex_hulls {
+  index: {{ .Index }}
+  count: {{ .Count }}
+  collision_group: "{{ .Group }}"
+}
+{{- end }}
+{{- range .HullPoints }}
+convex_hull_points: {{ printf "%.1f" . }}
+{{- end }}
+{{- range .CollisionGroups }}
+collision_groups: "{{ . }}"
+{{- else }}
+collision_groups: "default"
+{{- end }}
+extrude_borders: 2
+inner_padding: 0
+sprite_trim_mode: SPRITE_TRIM_MODE_OFF
+`))
@@ -0,0 +1,838 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pranavraja/asefile"
+)
+
+// guiNode is a node of a generated .gui. X and Y are where its pivot is, relative to Parent if it has one
+type guiNode struct {
+	ID      string
+	Type    string
+	Parent  string
+	X, Y    float64
+	W, H    int
+	Pivot   string
+	XAnchor string
+	YAnchor string
+	Adjust  string
+	Texture string
+	Color   [4]float64
+	Hidden  bool
+	// Button nodes are checked for clicks by the generated gui script
+	Button bool
+	// States are the images of a button other than its texture, see buttonStates
+	States []buttonState
+	// Clipping nodes clip their children with a stencil
+	Clipping     bool
+	ClipInverted bool
+	ClipVisible  bool
+	// PieBounds, InnerRadius, FillAngle and Vertices are set for pie nodes
+	PieBounds   string
+	InnerRadius float64
+	FillAngle   float64
+	Vertices    int
+	// Template is the gui a template node instantiates
+	Template string
+	// Text and Font are set for text nodes
+	Text string
+	Font string
+	// Overrides are the fields a layout changes, see layoutFields
+	Overrides []int
+
+	// rect is where the node is in the aseprite file
+	rect image.Rectangle
+	// layer is the index of the aseprite layer the node came from, or -1 for slices
+	layer int
+	// autoAnchor anchors the node to the screen edges it's closest to, see inferAnchors
+	autoAnchor bool
+}
+
+// buttonState is the image of a button in a state, as its animation in ui.atlas
+type buttonState struct {
+	State string
+	Image string
+}
+
+// buttonStates are the layer suffixes for the images of buttons in other states, e.g. play.hover
+var buttonStates = []string{"hover", "pressed", "disabled"}
+
+// guiFont is a font referenced by the text nodes of a gui
+type guiFont struct {
+	Name string
+	Path string
+}
+
+type gui struct {
+	Name     string
+	Textures []string
+	Fonts    []guiFont
+	Nodes    []guiNode
+	Layouts  []guiLayout
+}
+
+// pivots are where the pivot of a node is, as a fraction of its size from the top left corner
+var pivots = map[string][2]float64{
+	"PIVOT_NW":     {0, 0},
+	"PIVOT_N":      {0.5, 0},
+	"PIVOT_NE":     {1, 0},
+	"PIVOT_W":      {0, 0.5},
+	"PIVOT_CENTER": {0.5, 0.5},
+	"PIVOT_E":      {1, 0.5},
+	"PIVOT_SW":     {0, 1},
+	"PIVOT_S":      {0.5, 1},
+	"PIVOT_SE":     {1, 1},
+}
+
+// newGUINode creates a node for a rectangle of the aseprite file, from its top left corner
+func newGUINode(id string, x, y, w, h, height int) guiNode {
+	n := guiNode{
+		ID:      id,
+		Type:    "TYPE_BOX",
+		W:       w,
+		H:       h,
+		Color:   [4]float64{1, 1, 1, 1},
+		XAnchor: "XANCHOR_NONE",
+		YAnchor: "YANCHOR_NONE",
+		Adjust:  "ADJUST_MODE_FIT",
+		rect:    image.Rect(x, y, x+w, y+h),
+		layer:   -1,
+	}
+	n.setPivot("PIVOT_NW", x, y, height)
+	return n
+}
+
+// setPivot moves the position of a node to its pivot
+func (n *guiNode) setPivot(pivot string, x, y, height int) {
+	p := pivots[pivot]
+	n.Pivot = pivot
+	n.X = float64(x) + p[0]*float64(n.W)
+	// y coordinates are reversed in defold
+	n.Y = float64(height) - float64(y) - p[1]*float64(n.H)
+}
+
+// nodeSuffixes are the name suffixes that configure a node, e.g. coins.top.right or logo.center.zoom,
+// as the user data key and value they stand for
+var nodeSuffixes = map[string][2]string{
+	"top":     {"yanchor", "top"},
+	"bottom":  {"yanchor", "bottom"},
+	"left":    {"xanchor", "left"},
+	"right":   {"xanchor", "right"},
+	"auto":    {"anchor", "auto"},
+	"nw":      {"pivot", "nw"},
+	"n":       {"pivot", "n"},
+	"ne":      {"pivot", "ne"},
+	"w":       {"pivot", "w"},
+	"center":  {"pivot", "center"},
+	"e":       {"pivot", "e"},
+	"sw":      {"pivot", "sw"},
+	"s":       {"pivot", "s"},
+	"se":      {"pivot", "se"},
+	"fit":     {"adjust", "fit"},
+	"zoom":    {"adjust", "zoom"},
+	"stretch": {"adjust", "stretch"},
+	"button":  {"button", "true"},
+	"clip":    {"clip", "true"},
+	"pie":     {"type", "pie"},
+}
+
+// nodeData removes the suffixes of a layer or slice name and adds them to its user data,
+// which takes precedence. Other suffixes, like .text, stay in the name
+func nodeData(name string, data map[string]string) string {
+	parts := strings.Split(name, ".")
+	name = parts[0]
+	for _, part := range parts[1:] {
+		// e.g. dialog.template=confirm
+		if template, ok := strings.CutPrefix(part, "template="); ok {
+			if _, ok := data["template"]; !ok {
+				data["template"] = template
+			}
+			continue
+		}
+		suffix, ok := nodeSuffixes[part]
+		if !ok {
+			name += "." + part
+			continue
+		}
+		if _, ok := data[suffix[0]]; !ok {
+			data[suffix[0]] = suffix[1]
+		}
+	}
+	return name
+}
+
+// configureNode sets the pivot, anchors and adjust mode of a node from user data, e.g.
+// "pivot=center anchor=top-right adjust=zoom", or "anchor=auto" to infer the anchors, see inferAnchors.
+// It also makes buttons, clipping nodes, pies and templates, see configureType.
+// x and y are the top left corner of the node, to move its position to the new pivot
+func configureNode(n *guiNode, data map[string]string, x, y, height int, autoAnchor bool) error {
+	if v, ok := data["pivot"]; ok {
+		pivot := "PIVOT_" + strings.ToUpper(v)
+		if _, ok := pivots[pivot]; !ok {
+			return fmt.Errorf("unknown pivot %s, expected e.g. center, nw or se", v)
+		}
+		n.setPivot(pivot, x, y, height)
+	}
+	n.autoAnchor = autoAnchor
+	if v, ok := data["anchor"]; ok {
+		n.autoAnchor = v == "auto"
+		if !n.autoAnchor {
+			// e.g. top-right or left
+			for _, side := range strings.Split(v, "-") {
+				switch side {
+				case "top", "bottom":
+					data["yanchor"] = side
+				case "left", "right":
+					data["xanchor"] = side
+				case "none":
+					data["xanchor"], data["yanchor"] = side, side
+				default:
+					return fmt.Errorf("unknown anchor %s, expected e.g. top-left, right or auto", v)
+				}
+			}
+		}
+	}
+	if v, ok := data["xanchor"]; ok {
+		if v != "left" && v != "right" && v != "none" {
+			return fmt.Errorf("unknown x anchor %s, expected left, right or none", v)
+		}
+		n.XAnchor, n.autoAnchor = "XANCHOR_"+strings.ToUpper(v), false
+	}
+	if v, ok := data["yanchor"]; ok {
+		if v != "top" && v != "bottom" && v != "none" {
+			return fmt.Errorf("unknown y anchor %s, expected top, bottom or none", v)
+		}
+		n.YAnchor, n.autoAnchor = "YANCHOR_"+strings.ToUpper(v), false
+	}
+	if err := configureType(n, data, x, y, height); err != nil {
+		return err
+	}
+	if v, ok := data["button"]; ok {
+		button, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid button %q, expected true or false", v)
+		}
+		n.Button = button
+	}
+	if v, ok := data["adjust"]; ok {
+		if v != "fit" && v != "zoom" && v != "stretch" {
+			return fmt.Errorf("unknown adjust mode %s, expected fit, zoom or stretch", v)
+		}
+		n.Adjust = "ADJUST_MODE_" + strings.ToUpper(v)
+	}
+	return nil
+}
+
+// configureType sets up clipping, pie and template nodes from user data, e.g. "clip=true inverted=true",
+// "type=pie fill=270 inner=8 bounds=rectangle vertices=64" or "template=confirm"
+func configureType(n *guiNode, data map[string]string, x, y, height int) error {
+	var err error
+	bools := map[string]*bool{"clip": &n.Clipping, "inverted": &n.ClipInverted}
+	for key, value := range bools {
+		if v, ok := data[key]; ok {
+			if *value, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("invalid %s %q, expected true or false", key, v)
+			}
+		}
+	}
+	// clipping nodes without an image only clip, instead of drawing a box
+	n.ClipVisible = n.Texture != ""
+	if template, ok := data["template"]; ok {
+		// the template is positioned by the bottom left corner of its gui
+		n.Type, n.Template, n.Texture = "TYPE_TEMPLATE", template, ""
+		n.setPivot("PIVOT_SW", x, y, height)
+		return nil
+	}
+	if v, ok := data["type"]; ok {
+		if v != "pie" {
+			return fmt.Errorf("unknown node type %s, expected pie", v)
+		}
+		n.Type = "TYPE_PIE"
+		n.PieBounds, n.FillAngle, n.Vertices = "PIEBOUNDS_ELLIPSE", 360, 32
+		if v, ok := data["bounds"]; ok {
+			if v != "ellipse" && v != "rectangle" {
+				return fmt.Errorf("unknown pie bounds %s, expected ellipse or rectangle", v)
+			}
+			n.PieBounds = "PIEBOUNDS_" + strings.ToUpper(v)
+		}
+		numbers := map[string]*float64{"fill": &n.FillAngle, "inner": &n.InnerRadius}
+		for key, value := range numbers {
+			if v, ok := data[key]; ok {
+				if *value, err = strconv.ParseFloat(v, 64); err != nil {
+					return fmt.Errorf("invalid %s %q, expected a number", key, v)
+				}
+			}
+		}
+		if v, ok := data["vertices"]; ok {
+			if n.Vertices, err = strconv.Atoi(v); err != nil {
+				return fmt.Errorf("invalid vertices %q, expected a whole number", v)
+			}
+		}
+	}
+	return nil
+}
+
+// inferAnchors anchors a node to the screen edges it's closest to, unless it's in the middle third
+// of the screen, so e.g. a score in the top right corner stays there on wider screens
+func inferAnchors(n *guiNode, width, height int) {
+	center := n.rect.Min.Add(n.rect.Max).Div(2)
+	switch {
+	case center.X < width/3:
+		n.XAnchor = "XANCHOR_LEFT"
+	case center.X > width*2/3:
+		n.XAnchor = "XANCHOR_RIGHT"
+	}
+	switch {
+	case center.Y < height/3:
+		n.YAnchor = "YANCHOR_TOP"
+	case center.Y > height*2/3:
+		n.YAnchor = "YANCHOR_BOTTOM"
+	}
+}
+
+// textAlignments are the pivots of text nodes, which defold aligns their text to
+var textAlignments = map[string]string{
+	"left":   "PIVOT_W",
+	"center": "PIVOT_CENTER",
+	"right":  "PIVOT_E",
+}
+
+// configureText turns a node into a text node from user data, e.g. "text=Start font=pixel align=left color=#ffcc00".
+// The text defaults to the node id, and the font to defold's default font
+func (g *gui) configureText(n *guiNode, x, y, height int, data map[string]string) error {
+	n.Type = "TYPE_TEXT"
+	n.Text = n.ID
+	if v, ok := data["text"]; ok {
+		n.Text = v
+	}
+	font := guiFont{Name: "default", Path: "/builtins/fonts/default.font"}
+	if v, ok := data["font"]; ok {
+		// fonts are either a path, or the name of a font imported from fonts/
+		font = guiFont{Name: v, Path: fmt.Sprintf("/import/%s.font", v)}
+		if strings.HasPrefix(v, "/") {
+			font.Name = strings.TrimSuffix(v[strings.LastIndex(v, "/")+1:], ".font")
+			font.Path = v
+		}
+	}
+	n.Font = font.Name
+	exists := false
+	for _, f := range g.Fonts {
+		if f.Name == font.Name {
+			if f.Path != font.Path {
+				return fmt.Errorf("font %s is both %s and %s", f.Name, f.Path, font.Path)
+			}
+			exists = true
+		}
+	}
+	if !exists {
+		g.Fonts = append(g.Fonts, font)
+	}
+	align := "center"
+	if v, ok := data["align"]; ok {
+		align = v
+	}
+	pivot, ok := textAlignments[align]
+	if !ok {
+		return fmt.Errorf("unknown alignment %s, expected left, center or right", align)
+	}
+	n.setPivot(pivot, x, y, height)
+	if v, ok := data["color"]; ok {
+		color, err := parseColor(v)
+		if err != nil {
+			return err
+		}
+		n.Color = color
+	}
+	return nil
+}
+
+// parseColor reads colors like #ffcc00 or #ffcc0080
+func parseColor(text string) ([4]float64, error) {
+	color := [4]float64{1, 1, 1, 1}
+	hex := strings.TrimPrefix(text, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color, fmt.Errorf("invalid color %q, expected e.g. #ffcc00", text)
+	}
+	for i := 0; i < len(hex)/2; i++ {
+		v, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return color, fmt.Errorf("invalid color %q, expected e.g. #ffcc00", text)
+		}
+		color[i] = float64(v) / 255
+	}
+	return color, nil
+}
+
+// uiFile is the state shared between the frames of a UI file
+type uiFile struct {
+	name          string
+	file          asefile.AsepriteFile
+	width, height int
+	layers        []asefile.AsepriteLayerChunk2004
+	// names and data are the layer names without their suffixes and their user data, see nodeData
+	names  []string
+	data   []map[string]string
+	slices []asefile.AsepriteSliceChunk2022
+	// cels are the image cels of the default layout by layer
+	cels map[uint16]asefile.AsepriteCelChunk2005
+	// animated are the layers with an animation instead of an image, with the bounds of all its frames, see uiAnimations
+	animated map[uint16]image.Rectangle
+}
+
+// guiLayout is a layout of a gui, with the nodes that differ from the default layout
+type guiLayout struct {
+	Name  string
+	Nodes []guiNode
+}
+
+// layoutTag returns the layout of a tag named Landscape, Portrait or e.g. layout:Tablet for other display profiles
+func layoutTag(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "landscape":
+		return "Landscape", true
+	case "portrait":
+		return "Portrait", true
+	}
+	return strings.CutPrefix(name, "layout:")
+}
+
+// layoutFields are the fields of a node that layouts can override, as their field numbers in defold's
+// NodeDesc message, with whether they differ between two nodes
+var layoutFields = []struct {
+	number int
+	differ func(a, b guiNode) bool
+}{
+	{1, func(a, b guiNode) bool { return a.X != b.X || a.Y != b.Y }},
+	{4, func(a, b guiNode) bool { return a.W != b.W || a.H != b.H }},
+	{5, func(a, b guiNode) bool { return a.Color != b.Color }},
+	{8, func(a, b guiNode) bool { return a.Text != b.Text }},
+	{9, func(a, b guiNode) bool { return a.Texture != b.Texture }},
+	{12, func(a, b guiNode) bool { return a.XAnchor != b.XAnchor }},
+	{13, func(a, b guiNode) bool { return a.YAnchor != b.YAnchor }},
+	{14, func(a, b guiNode) bool { return a.Pivot != b.Pivot }},
+	{17, func(a, b guiNode) bool { return a.Adjust != b.Adjust }},
+}
+
+// importUI generates a gui from the first frame of a UI file. Frames tagged as layouts, see layoutTag,
+// override the nodes that differ from it in that layout. Besides the images and animations for ui.atlas,
+// it returns the uis used as templates
+func (a asepriteImporter) importUI(filename string, file asefile.AsepriteFile) ([]element, []animation, []string, error) {
+	u := uiFile{
+		name:     filename,
+		file:     file,
+		width:    int(file.Header.WidthInPixels),
+		height:   int(file.Header.HeightInPixels),
+		cels:     make(map[uint16]asefile.AsepriteCelChunk2005),
+		animated: make(map[uint16]image.Rectangle),
+	}
+	// Layers and slices are only stored once, usually in the first frame
+	for _, frame := range file.Frames {
+		u.layers = append(u.layers, frame.Layers...)
+		u.slices = append(u.slices, frame.Slices...)
+	}
+	u.names = make([]string, len(u.layers))
+	u.data = make([]map[string]string, len(u.layers))
+	for i, layer := range u.layers {
+		u.data[i] = userData(fmt.Sprintf("layer %s in %s", layer.LayerName, filename), layer.UserData.Text)
+		u.names[i] = nodeData(layer.LayerName, u.data[i])
+	}
+	anims, err := a.uiAnimations(&u)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	g := gui{Name: filename}
+	g.Textures = append(g.Textures, "ui")
+	nodes, images, err := a.uiNodes(&u, &g, 0, "")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	g.Nodes = nodes
+	for _, frame := range file.Frames {
+		for _, tag := range frame.Tags.Tags {
+			name, ok := layoutTag(tag.TagName)
+			if !ok {
+				continue
+			}
+			nodes, layoutImages, err := a.uiNodes(&u, &g, int(tag.FromFrame), name)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			images = append(images, layoutImages...)
+			layout := guiLayout{Name: name}
+			for _, n := range nodes {
+				found := false
+				for _, d := range g.Nodes {
+					if d.ID != n.ID {
+						continue
+					}
+					found = true
+					for _, field := range layoutFields {
+						if field.differ(d, n) {
+							n.Overrides = append(n.Overrides, field.number)
+						}
+					}
+				}
+				if !found {
+					log.Printf("WARNING: skipping node %s of layout %s in %s, layouts can only change nodes of the first frame", n.ID, name, filename)
+					continue
+				}
+				if len(n.Overrides) > 0 {
+					layout.Nodes = append(layout.Nodes, n)
+				}
+			}
+			g.Layouts = append(g.Layouts, layout)
+		}
+	}
+	for _, slice := range u.slices {
+		if !strings.HasSuffix(slice.Name, ".text") {
+			g.Textures = append(g.Textures, "all")
+			break
+		}
+	}
+	if err := a.render(filename+".gui", guiTemplate, g); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := a.render(filename+".go", guiObjectTemplate, g); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := a.render(filename+"_nodes.lua", guiNodesTemplate, g); err != nil {
+		return nil, nil, nil, err
+	}
+	// The script is for hand written code, so it's only generated once
+	if err := a.renderIfMissing(filename+".gui_script", guiScriptTemplate, g); err != nil {
+		return nil, nil, nil, err
+	}
+	var templates []string
+	for _, n := range g.Nodes {
+		if n.Template != "" {
+			templates = append(templates, n.Template)
+		}
+	}
+	return images, anims, templates, nil
+}
+
+// sameCel returns whether two cels have the same image
+func sameCel(a, b asefile.AsepriteCelChunk2005) bool {
+	return a.WidthInPix == b.WidthInPix && a.HeightInPix == b.HeightInPix && bytes.Equal(a.RawCelData, b.RawCelData)
+}
+
+// playbackModes are the defold playback modes for aseprite's loop directions: forward, reverse and ping-pong
+var playbackModes = []string{"FORWARD", "BACKWARD", "PINGPONG"}
+
+// uiAnimations exports the image layers that change between the frames of the first tag that isn't a layout,
+// or else between the first frame and the frames outside of layouts, as animations. They're named like the image
+// of the layer would be, so nodes play them without changes. The frames are written as <ui>_<layer>_<frame>.png
+func (a asepriteImporter) uiAnimations(u *uiFile) ([]animation, error) {
+	var (
+		frames   []int
+		tag      *asefile.AsepriteTagsChunk2018Tag
+		inLayout = make(map[int]bool)
+	)
+	for _, frame := range u.file.Frames {
+		for i, t := range frame.Tags.Tags {
+			if _, ok := layoutTag(t.TagName); ok {
+				for f := int(t.FromFrame); f <= int(t.ToFrame); f++ {
+					inLayout[f] = true
+				}
+			} else if tag == nil {
+				tag = &frame.Tags.Tags[i]
+			}
+		}
+	}
+	if tag != nil {
+		for f := int(tag.FromFrame); f <= int(tag.ToFrame) && f < len(u.file.Frames); f++ {
+			frames = append(frames, f)
+		}
+	} else {
+		for f := range u.file.Frames {
+			if f == 0 || !inLayout[f] {
+				frames = append(frames, f)
+			}
+		}
+	}
+	if len(frames) < 2 {
+		return nil, nil
+	}
+	cels := make([]map[uint16]asefile.AsepriteCelChunk2005, len(frames))
+	for i, f := range frames {
+		cels[i] = make(map[uint16]asefile.AsepriteCelChunk2005)
+		for _, cel := range frameCels(u.file, f) {
+			if cel.CelType == 2 {
+				cels[i][cel.LayerIndex] = cel
+			}
+		}
+	}
+	var anims []animation
+	for i, layer := range u.layers {
+		index := uint16(i)
+		name := u.names[i]
+		if _, _, isState := buttonLayer(name); layer.LayerType != 0 || isState || strings.HasSuffix(name, ".text") {
+			continue
+		}
+		first, hasFirst := cels[0][index]
+		changed := false
+		for _, frame := range cels[1:] {
+			cel, ok := frame[index]
+			changed = changed || ok != hasFirst || (ok && !sameCel(first, cel))
+		}
+		if !changed {
+			continue
+		}
+		// aseprite trims cels, so every frame is drawn on the bounds of all of them to keep it in place
+		var bounds image.Rectangle
+		for _, frame := range cels {
+			if cel, ok := frame[index]; ok {
+				bounds = bounds.Union(celImage(cel).Bounds())
+			}
+		}
+		u.animated[index] = bounds
+		anim := animation{ID: fmt.Sprintf("%s_%s", u.name, name), PlaybackMode: "PLAYBACK_LOOP_FORWARD"}
+		if tag != nil && int(tag.LoopAnimDirection) < len(playbackModes) {
+			anim.PlaybackMode = "PLAYBACK_LOOP_" + playbackModes[tag.LoopAnimDirection]
+			if tag.Repeats == 1 {
+				anim.PlaybackMode = "PLAYBACK_ONCE_" + playbackModes[tag.LoopAnimDirection]
+			}
+		}
+		for j, f := range frames {
+			img := fmt.Sprintf("%s_%s_%d.png", u.name, name, f)
+			// frames without a cel are empty
+			frame := image.NewRGBA(image.Rectangle{Max: bounds.Size()})
+			if cel, ok := cels[j][index]; ok {
+				src := celImage(cel)
+				draw.Draw(frame, src.Bounds().Sub(bounds.Min), src, src.Bounds().Min, draw.Src)
+			}
+			if err := a.writeImage("img/"+img, frame); err != nil {
+				return nil, err
+			}
+			anim.Frames = append(anim.Frames, img)
+		}
+		var err error
+		if anim.FPS, err = animationFPS(u.file, anim.ID, frames); err != nil {
+			return nil, err
+		}
+		anims = append(anims, anim)
+	}
+	return anims, nil
+}
+
+// buttonLayer returns the button and state of a layer named after a button state, e.g. play.hover
+func buttonLayer(name string) (string, string, bool) {
+	for _, state := range buttonStates {
+		if button, ok := strings.CutSuffix(name, "."+state); ok {
+			return button, state, true
+		}
+	}
+	return "", "", false
+}
+
+// uiNodes creates the nodes of a frame, and writes the images they use. Images of a layout
+// are only written if they differ from the default one, and are suffixed with the layout
+func (a asepriteImporter) uiNodes(u *uiFile, g *gui, frame int, layout string) ([]guiNode, []element, error) {
+	var (
+		nodes  []guiNode
+		images []element
+		states = make(map[string][]buttonState)
+		cels   = frameCels(u.file, frame)
+	)
+	// Animated layers get a node even in the frames they're empty in
+	for index := range u.animated {
+		found := false
+		for _, cel := range cels {
+			found = found || (cel.LayerIndex == index && cel.CelType == 2)
+		}
+		if !found {
+			cels = append(cels, asefile.AsepriteCelChunk2005{LayerIndex: index, CelType: 2})
+		}
+	}
+	sort.SliceStable(cels, func(i, j int) bool { return cels[i].LayerIndex < cels[j].LayerIndex })
+	for _, cel := range cels {
+		if int(cel.LayerIndex) >= len(u.layers) {
+			return nil, nil, fmt.Errorf("cel in %s refers to unknown layer %d", u.name, cel.LayerIndex)
+		}
+		if cel.CelType != 2 {
+			continue
+		}
+		layer, data := u.layers[cel.LayerIndex], u.data[cel.LayerIndex]
+		// Layers named e.g. play.hover are only images of the button play, the default layout has them all
+		if button, state, ok := buttonLayer(u.names[cel.LayerIndex]); ok {
+			if layout == "" {
+				img := fmt.Sprintf("%s_%s", button, state)
+				if err := a.writePNG(fmt.Sprintf("img/%s_%s.png", u.name, img), cel); err != nil {
+					return nil, nil, err
+				}
+				images = append(images, element{Group: u.name, Name: img})
+				states[button] = append(states[button], buttonState{State: state, Image: fmt.Sprintf("%s_%s", u.name, img)})
+			}
+			continue
+		}
+		x, y, w, h := int(cel.X), int(cel.Y), int(cel.WidthInPix), int(cel.HeightInPix)
+		bounds, animated := u.animated[cel.LayerIndex]
+		if animated {
+			x, y, w, h = bounds.Min.X, bounds.Min.Y, bounds.Dx(), bounds.Dy()
+		}
+		// Layers named e.g. title.text are labels, their pixels only show where the text goes
+		name, isText := strings.CutSuffix(u.names[cel.LayerIndex], ".text")
+		n := newGUINode(name, x, y, w, h, u.height)
+		n.layer = int(cel.LayerIndex)
+		_, isTemplate := data["template"]
+		switch {
+		case isTemplate:
+			// the pixels of template layers also only show where the template goes
+		case isText:
+			if err := g.configureText(&n, x, y, u.height, data); err != nil {
+				return nil, nil, fmt.Errorf("layer %s in %s: %s", layer.LayerName, u.name, err)
+			}
+		default:
+			img := name
+			if layout == "" {
+				u.cels[cel.LayerIndex] = cel
+			} else if d, ok := u.cels[cel.LayerIndex]; !animated && (!ok || !sameCel(d, cel)) {
+				img = fmt.Sprintf("%s_%s", name, layout)
+			}
+			// animated layers have an animation with the same name as their image would have
+			n.Texture = fmt.Sprintf("ui/%s_%s", u.name, img)
+			if !animated && (layout == "" || img != name) {
+				if err := a.writePNG(fmt.Sprintf("img/%s_%s.png", u.name, img), cel); err != nil {
+					return nil, nil, err
+				}
+				images = append(images, element{Group: u.name, Name: img})
+			}
+		}
+		if err := configureNode(&n, data, x, y, u.height, a.autoAnchor); err != nil {
+			return nil, nil, fmt.Errorf("layer %s in %s: %s", layer.LayerName, u.name, err)
+		}
+		nodes = append(nodes, n)
+	}
+	for i, n := range nodes {
+		if s, ok := states[n.ID]; ok {
+			normal := buttonState{State: "normal", Image: strings.TrimPrefix(n.Texture, "ui/")}
+			nodes[i].Button, nodes[i].States = true, append([]buttonState{normal}, s...)
+		}
+	}
+	for _, slice := range u.slices {
+		data := userData(fmt.Sprintf("slice %s in %s", slice.Name, u.name), slice.UserData.Text)
+		name := nodeData(slice.Name, data)
+		name, isText := strings.CutSuffix(name, ".text")
+		for _, key := range sliceKeys(slice, frame, frame) {
+			x, y, w, h := int(key.SliceXOriginCoords), int(key.SliceYOriginCoords), int(key.SliceWidth), int(key.SliceHeight)
+			n := newGUINode(name, x, y, w, h, u.height)
+			if isText {
+				if err := g.configureText(&n, x, y, u.height, data); err != nil {
+					return nil, nil, fmt.Errorf("slice %s in %s: %s", slice.Name, u.name, err)
+				}
+			}
+			if err := configureNode(&n, data, x, y, u.height, a.autoAnchor); err != nil {
+				return nil, nil, fmt.Errorf("slice %s in %s: %s", slice.Name, u.name, err)
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	nodes, err := a.groupNodes(u.layers, u.names, u.data, nodes, u.height)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", u.name, err)
+	}
+	// Only nodes without a parent are anchored to the screen
+	for i, n := range nodes {
+		if n.autoAnchor && n.Parent == "" {
+			inferAnchors(&nodes[i], u.width, u.height)
+		}
+	}
+	return nodes, images, nil
+}
+
+// groupNodes adds a hidden node for every layer group, covering its contents, and makes it the parent of
+// the nodes of the layers in the group, as well as the slices within its bounds. Positions are made relative
+// to the parent. The group nodes come first, as parents have to be listed before their children
+func (a asepriteImporter) groupNodes(layers []asefile.AsepriteLayerChunk2004, names []string, data []map[string]string, nodes []guiNode, height int) ([]guiNode, error) {
+	parents := layerParents(layers)
+	var groups []guiNode
+	// group is the index in groups of the node for each group layer
+	group := make(map[int]int)
+	for i, layer := range layers {
+		if layer.LayerType != 1 {
+			continue
+		}
+		var bounds image.Rectangle
+		for _, n := range nodes {
+			for p := n.layer; p >= 0; p = parents[p] {
+				if p == i {
+					bounds = bounds.Union(n.rect)
+					break
+				}
+			}
+		}
+		if bounds.Empty() {
+			continue
+		}
+		n := newGUINode(names[i], bounds.Min.X, bounds.Min.Y, bounds.Dx(), bounds.Dy(), height)
+		if err := configureNode(&n, data[i], bounds.Min.X, bounds.Min.Y, height, a.autoAnchor); err != nil {
+			return nil, fmt.Errorf("layer %s: %s", layer.LayerName, err)
+		}
+		// clipping groups have to be visible to clip, they don't draw anything either way
+		n.Hidden = !n.Clipping
+		n.layer = i
+		group[i] = len(groups)
+		groups = append(groups, n)
+	}
+	// parent returns the closest group with a node that contains a layer
+	parent := func(layer int) (int, bool) {
+		for p := parents[layer]; p >= 0; p = parents[p] {
+			if g, ok := group[p]; ok {
+				return g, true
+			}
+		}
+		return 0, false
+	}
+	// groups keeps the absolute positions, as all gets the relative ones
+	all := append(append([]guiNode{}, groups...), nodes...)
+	for i, n := range all {
+		var (
+			p  int
+			ok bool
+		)
+		if n.layer >= 0 {
+			p, ok = parent(n.layer)
+		} else {
+			// slices go in the smallest group around them
+			for g, candidate := range groups {
+				if n.rect.In(candidate.rect) && (!ok || candidate.rect.In(groups[p].rect)) {
+					p, ok = g, true
+				}
+			}
+		}
+		if ok {
+			all[i].Parent = groups[p].ID
+			all[i].X -= groups[p].X
+			all[i].Y -= groups[p].Y
+		}
+	}
+	// Slices that clip become the parents of the nodes of layers within their bounds and in the same group,
+	// so they come before them
+	var clips, rest []guiNode
+	for _, n := range all[len(groups):] {
+		if n.layer < 0 && n.Clipping {
+			clips = append(clips, n)
+		} else {
+			rest = append(rest, n)
+		}
+	}
+	for i, n := range rest {
+		for _, clip := range clips {
+			if n.layer >= 0 && n.Parent == clip.Parent && n.rect.In(clip.rect) {
+				rest[i].Parent = clip.ID
+				rest[i].X -= clip.X
+				rest[i].Y -= clip.Y
+				break
+			}
+		}
+	}
+	return append(append(all[:len(groups)], clips...), rest...), nil
+}
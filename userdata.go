@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pranavraja/asefile"
+)
+
+// component groups the script property overrides of one component of a game object
+type component struct {
+	ID         string
+	Properties []property
+}
+
+type property struct {
+	ID    string
+	Value string
+	Type  string
+}
+
+// asepriteUserData is the user data of the layers, cels, tilesets and tiles of an aseprite file.
+// asefile gives the user data of a cel to the layer or tags before it, and skips that of tilesets
+type asepriteUserData struct {
+	layers []string
+	// cels are by frame and layer index
+	cels     map[[2]int]string
+	tilesets map[uint32]string
+	// tiles are by tileset id and tile index
+	tiles map[uint32]map[int]string
+}
+
+// readUserData reads the user data of every chunk of an aseprite file, which belongs to the chunk before it,
+// except after a tileset, where the first is the tileset's and the rest are of its tiles in order
+func readUserData(file string) (asepriteUserData, error) {
+	u := asepriteUserData{
+		cels:     make(map[[2]int]string),
+		tilesets: make(map[uint32]string),
+		tiles:    make(map[uint32]map[int]string),
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return u, err
+	}
+	r := bytes.NewReader(contents)
+	var header struct {
+		Size     uint32
+		Magic    uint16
+		Frames   uint16
+		Reserved [120]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return u, err
+	}
+	for frame := 0; frame < int(header.Frames); frame++ {
+		var frameHeader struct {
+			Size      uint32
+			Magic     uint16
+			OldChunks uint16
+			Duration  uint16
+			Reserved  [2]byte
+			Chunks    uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &frameHeader); err != nil {
+			return u, err
+		}
+		chunks := int(frameHeader.Chunks)
+		if chunks == 0 {
+			chunks = int(frameHeader.OldChunks)
+		}
+		// owner is the type of the chunk the next user data belongs to, index the layer index of a layer or cel
+		// or the id of a tileset, and tile the index of the next tile of a tileset, -1 for the tileset itself
+		var (
+			owner uint16
+			index int
+			tile  int
+		)
+		for i := 0; i < chunks; i++ {
+			var chunkHeader struct {
+				Size uint32
+				Type uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &chunkHeader); err != nil {
+				return u, err
+			}
+			if chunkHeader.Size < 6 {
+				return u, fmt.Errorf("invalid chunk size %d in frame %d", chunkHeader.Size, frame)
+			}
+			data := make([]byte, chunkHeader.Size-6)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return u, err
+			}
+			const (
+				Layer    = 0x2004
+				Cel      = 0x2005
+				CelExtra = 0x2006
+				UserData = 0x2020
+				Tileset  = 0x2023
+			)
+			switch chunkHeader.Type {
+			case Layer:
+				owner, index = Layer, len(u.layers)
+				u.layers = append(u.layers, "")
+			case Cel:
+				if len(data) < 2 {
+					return u, fmt.Errorf("invalid cel in frame %d", frame)
+				}
+				owner, index = Cel, int(binary.LittleEndian.Uint16(data))
+			case Tileset:
+				if len(data) < 4 {
+					return u, fmt.Errorf("invalid tileset in frame %d", frame)
+				}
+				owner, index, tile = Tileset, int(binary.LittleEndian.Uint32(data)), -1
+			case CelExtra:
+				// the extra data of a cel comes between the cel and its user data
+			case UserData:
+				var text string
+				if len(data) >= 6 && binary.LittleEndian.Uint32(data)&1 != 0 {
+					n := int(binary.LittleEndian.Uint16(data[4:]))
+					if 6+n > len(data) {
+						return u, fmt.Errorf("invalid user data in frame %d", frame)
+					}
+					text = string(data[6 : 6+n])
+				}
+				switch owner {
+				case Layer:
+					u.layers[index] = text
+				case Cel:
+					u.cels[[2]int{frame, index}] = text
+				case Tileset:
+					if tile < 0 {
+						u.tilesets[uint32(index)] = text
+						u.tiles[uint32(index)] = make(map[int]string)
+					} else if text != "" {
+						u.tiles[uint32(index)][tile] = text
+					}
+					tile++
+				}
+			default:
+				// e.g. the user data of tags and slices, which asefile reads
+				owner = 0
+			}
+		}
+	}
+	return u, nil
+}
+
+// setLayers replaces the user data asefile gave the layers of file, which can be that of a cel
+func (u asepriteUserData) setLayers(file *asefile.AsepriteFile) {
+	i := 0
+	for f := range file.Frames {
+		for l := range file.Frames[f].Layers {
+			if i < len(u.layers) {
+				file.Frames[f].Layers[l].UserData.Text = u.layers[i]
+			}
+			i++
+		}
+	}
+}
+
+// parseUserData reads the text of aseprite user data, either as a JSON object
+// or as key=value pairs separated by spaces or semicolons, e.g. "health=5 speed=2.5"
+func parseUserData(text string) (map[string]string, error) {
+	values := make(map[string]string)
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return values, nil
+	}
+	if strings.HasPrefix(text, "{") {
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			return nil, fmt.Errorf("invalid JSON user data %q: %s", text, err)
+		}
+		for k, v := range fields {
+			if list, ok := v.([]any); ok {
+				// e.g. [1, 2, 3] becomes 1,2,3, like a vector in key=value user data
+				var items []string
+				for _, item := range list {
+					items = append(items, fmt.Sprint(item))
+				}
+				v = strings.Join(items, ",")
+			}
+			values[k] = fmt.Sprint(v)
+		}
+		return values, nil
+	}
+	for _, pair := range strings.FieldsFunc(text, func(r rune) bool { return r == ';' || unicode.IsSpace(r) }) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid user data %q: expected key=value", pair)
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return values, nil
+}
+
+// userData parses user data like parseUserData, but only warns about text that is neither,
+// as aseprite user data is also used for notes like "background art"
+func userData(owner, text string) map[string]string {
+	data, err := parseUserData(text)
+	if err != nil {
+		log.Printf("WARNING: ignoring the user data of %s: %s", owner, err)
+		return make(map[string]string)
+	}
+	return data
+}
+
+// mergeUserData combines user data, e.g. of a layer and one of its cels, later values overriding earlier ones
+func mergeUserData(datas ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, data := range datas {
+		maps.Copy(merged, data)
+	}
+	return merged
+}
+
+// componentProperties turns user data into script property overrides, skipping the keys the
+// importer uses itself. Keys are of the form component#property, or just property for
+// the component with id script. The type of each value is inferred
+func componentProperties(data map[string]string, reserved ...string) []component {
+	var keys []string
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var components []component
+	for _, k := range keys {
+		if slices.Contains(reserved, k) {
+			continue
+		}
+		id, name, ok := strings.Cut(k, "#")
+		if !ok {
+			id, name = "script", k
+		}
+		p := inferProperty(name, data[k])
+		i := slices.IndexFunc(components, func(c component) bool { return c.ID == id })
+		if i < 0 {
+			i = len(components)
+			components = append(components, component{ID: id})
+		}
+		components[i].Properties = append(components[i].Properties, p)
+	}
+	return components
+}
+
+// inferProperty guesses the defold property type of value, falling back to a hash
+func inferProperty(name, value string) property {
+	p := property{ID: name, Value: value, Type: "PROPERTY_TYPE_HASH"}
+	if n, ok := parseNumber(value); ok {
+		p.Value, p.Type = formatNumber(n), "PROPERTY_TYPE_NUMBER"
+		return p
+	}
+	// only true and false, as t, f, 1 and 0 are more likely hashes or numbers
+	if value == "true" || value == "false" {
+		p.Type = "PROPERTY_TYPE_BOOLEAN"
+		return p
+	}
+	// colors like #ff0000, e.g. from LDtk color fields, are vector4s
+	if color, err := parseColor(value); err == nil && strings.HasPrefix(value, "#") {
+		var numbers []string
+		for _, c := range color {
+			numbers = append(numbers, formatNumber(c))
+		}
+		p.Value, p.Type = strings.Join(numbers, ", "), "PROPERTY_TYPE_VECTOR4"
+		return p
+	}
+	if parts := strings.Split(value, ","); len(parts) == 3 {
+		var numbers []string
+		for _, part := range parts {
+			n, ok := parseNumber(strings.TrimSpace(part))
+			if !ok {
+				break
+			}
+			numbers = append(numbers, formatNumber(n))
+		}
+		if len(numbers) == 3 {
+			p.Value, p.Type = strings.Join(numbers, ", "), "PROPERTY_TYPE_VECTOR3"
+			return p
+		}
+	}
+	// urls look like /path#component, collection:/path or #component
+	if strings.HasPrefix(value, "/") || strings.Contains(value, ":/") || isFragment(value) {
+		p.Type = "PROPERTY_TYPE_URL"
+	}
+	return p
+}
+
+// isFragment returns whether value is a url of a component of the same game object, e.g. #sprite
+func isFragment(value string) bool {
+	id, ok := strings.CutPrefix(value, "#")
+	if !ok || id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNumber parses a decimal number. Unlike strconv.ParseFloat it rejects nan, inf and hexadecimal
+// numbers like 0x1p3, which are more likely hashes
+func parseNumber(value string) (float64, bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) || strings.ContainsAny(value, "xX") {
+		return 0, false
+	}
+	return n, true
+}
+
+func formatNumber(n float64) string {
+	s := strconv.FormatFloat(n, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
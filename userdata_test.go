@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pranavraja/asefile"
+)
+
+func TestParseUserData(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "  ", map[string]string{}, false},
+		{"pairs", "health=5 speed=2.5", map[string]string{"health": "5", "speed": "2.5"}, false},
+		{"semicolons", "health=5;boss=true; z=0.9", map[string]string{"health": "5", "boss": "true", "z": "0.9"}, false},
+		{"empty value", "target=", map[string]string{"target": ""}, false},
+		{"json", `{"text": "Press start", "health": 5}`, map[string]string{"text": "Press start", "health": "5"}, false},
+		{"json list", `{"speed": [1, 2.5, 0]}`, map[string]string{"speed": "1,2.5,0"}, false},
+		{"note", "background art", nil, true},
+		{"invalid json", `{"health": }`, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseUserData(test.text)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseUserData(%q) error = %v, want error %t", test.text, err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseUserData(%q) = %v, want %v", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestUserDataIgnoresNotes(t *testing.T) {
+	if got := userData("layer bg", "background art"); len(got) != 0 {
+		t.Errorf("userData = %v, want no values", got)
+	}
+}
+
+func TestInferProperty(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantValue string
+		wantType  string
+	}{
+		{"5", "5.0", "PROPERTY_TYPE_NUMBER"},
+		{"-2.50", "-2.5", "PROPERTY_TYPE_NUMBER"},
+		{"true", "true", "PROPERTY_TYPE_BOOLEAN"},
+		{"false", "false", "PROPERTY_TYPE_BOOLEAN"},
+		// these parse as booleans in Go, but are meant as hashes or numbers
+		{"t", "t", "PROPERTY_TYPE_HASH"},
+		{"F", "F", "PROPERTY_TYPE_HASH"},
+		{"1", "1.0", "PROPERTY_TYPE_NUMBER"},
+		{"1,2,0", "1.0, 2.0, 0.0", "PROPERTY_TYPE_VECTOR3"},
+		{"1, 2.5, 3", "1.0, 2.5, 3.0", "PROPERTY_TYPE_VECTOR3"},
+		{"1,2", "1,2", "PROPERTY_TYPE_HASH"},
+		{"#ff0000", "1.0, 0.0, 0.0, 1.0", "PROPERTY_TYPE_VECTOR4"},
+		{"#00ff0000", "0.0, 1.0, 0.0, 0.0", "PROPERTY_TYPE_VECTOR4"},
+		{"/player#script", "/player#script", "PROPERTY_TYPE_URL"},
+		{"main:/player", "main:/player", "PROPERTY_TYPE_URL"},
+		{"#sprite", "#sprite", "PROPERTY_TYPE_URL"},
+		{"#", "#", "PROPERTY_TYPE_HASH"},
+		{"#not an id", "#not an id", "PROPERTY_TYPE_HASH"},
+		// strconv.ParseFloat reads these as numbers
+		{"nan", "nan", "PROPERTY_TYPE_HASH"},
+		{"inf", "inf", "PROPERTY_TYPE_HASH"},
+		{"-Infinity", "-Infinity", "PROPERTY_TYPE_HASH"},
+		{"0x1p3", "0x1p3", "PROPERTY_TYPE_HASH"},
+		{"1,nan,0", "1,nan,0", "PROPERTY_TYPE_HASH"},
+		{"patrol", "patrol", "PROPERTY_TYPE_HASH"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got := inferProperty("x", test.value)
+			if got.Value != test.wantValue || got.Type != test.wantType {
+				t.Errorf("inferProperty(%q) = %s %s, want %s %s", test.value, got.Value, got.Type, test.wantValue, test.wantType)
+			}
+		})
+	}
+}
+
+func TestComponentProperties(t *testing.T) {
+	data := map[string]string{"health": "5", "ai#mode": "patrol", "z": "0.9", "boss": "true"}
+	want := []component{
+		{ID: "ai", Properties: []property{{ID: "mode", Value: "patrol", Type: "PROPERTY_TYPE_HASH"}}},
+		{ID: "script", Properties: []property{
+			{ID: "boss", Value: "true", Type: "PROPERTY_TYPE_BOOLEAN"},
+			{ID: "health", Value: "5.0", Type: "PROPERTY_TYPE_NUMBER"},
+		}},
+	}
+	if got := componentProperties(data, "z"); !reflect.DeepEqual(got, want) {
+		t.Errorf("componentProperties = %+v, want %+v", got, want)
+	}
+}
+
+// aseChunk encodes an aseprite chunk, with the values written in little endian
+func aseChunk(t uint16, values ...any) []byte {
+	body := new(bytes.Buffer)
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			binary.Write(body, binary.LittleEndian, uint16(len(s)))
+			body.WriteString(s)
+			continue
+		}
+		binary.Write(body, binary.LittleEndian, v)
+	}
+	chunk := new(bytes.Buffer)
+	binary.Write(chunk, binary.LittleEndian, uint32(body.Len()+6))
+	binary.Write(chunk, binary.LittleEndian, t)
+	chunk.Write(body.Bytes())
+	return chunk.Bytes()
+}
+
+// aseFrames encodes an aseprite file with the chunks of each frame, only the chunk headers are valid
+func aseFrames(frames ...[][]byte) []byte {
+	file := new(bytes.Buffer)
+	binary.Write(file, binary.LittleEndian, struct {
+		Size     uint32
+		Magic    uint16
+		Frames   uint16
+		Reserved [120]byte
+	}{Magic: 0xa5e0, Frames: uint16(len(frames))})
+	for _, chunks := range frames {
+		body := bytes.Join(chunks, nil)
+		binary.Write(file, binary.LittleEndian, struct {
+			Size      uint32
+			Magic     uint16
+			OldChunks uint16
+			Duration  uint16
+			Reserved  [2]byte
+			Chunks    uint32
+		}{uint32(len(body) + 16), 0xf1fa, uint16(len(chunks)), 100, [2]byte{}, uint32(len(chunks))})
+		file.Write(body)
+	}
+	return file.Bytes()
+}
+
+func TestReadUserData(t *testing.T) {
+	var (
+		layer = func() []byte { return aseChunk(0x2004, [18]byte{}) }
+		cel   = func(layer uint16) []byte { return aseChunk(0x2005, layer, [14]byte{}) }
+		text  = func(s string) []byte { return aseChunk(0x2020, uint32(1), s) }
+		empty = aseChunk(0x2020, uint32(0))
+	)
+	contents := aseFrames(
+		[][]byte{
+			aseChunk(0x2023, uint32(3), [30]byte{}), text("kind=wood"), empty, text("health=3"),
+			layer(), text("health=5"), layer(), layer(),
+			aseChunk(0x2018, uint16(0), [8]byte{}),
+			cel(0), text("boss=true"), cel(2), aseChunk(0x2006, [36]byte{}), text("health=7"),
+		},
+		[][]byte{cel(1), text("speed=2")},
+	)
+	file := filepath.Join(t.TempDir(), "level.aseprite")
+	if err := os.WriteFile(file, contents, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readUserData(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := asepriteUserData{
+		layers:   []string{"health=5", "", ""},
+		cels:     map[[2]int]string{{0, 0}: "boss=true", {0, 2}: "health=7", {1, 1}: "speed=2"},
+		tilesets: map[uint32]string{3: "kind=wood"},
+		tiles:    map[uint32]map[int]string{3: {1: "health=3"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readUserData = %+v, want %+v", got, want)
+	}
+	if _, err := readUserData(filepath.Join(t.TempDir(), "missing.aseprite")); err == nil {
+		t.Error("readUserData read a missing file")
+	}
+	if err := os.WriteFile(file, contents[:len(contents)-3], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readUserData(file); err == nil {
+		t.Error("readUserData read a truncated file")
+	}
+
+	// asefile gives the last layer the user data of the cels after it
+	aseFile := asefile.AsepriteFile{Frames: []asefile.AsepriteFrame{{Layers: make([]asefile.AsepriteLayerChunk2004, 3)}}}
+	aseFile.Frames[0].Layers[2].UserData.Text = "health=7"
+	want.setLayers(&aseFile)
+	for i, layer := range aseFile.Frames[0].Layers {
+		if layer.UserData.Text != want.layers[i] {
+			t.Errorf("setLayers gave layer %d %q, want %q", i, layer.UserData.Text, want.layers[i])
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "text/template"
+
+var ldtkWorldTemplate = template.Must(template.New("").Parse(`
+local world = {}
+world.layout = {{ printf "%q" .Layout }}
+-- x and y are the bottom left corner of each level, with y pointing up like in defold
+world.levels = {
+{{- range .Levels }}
+	{ name = {{ printf "%q" .Name }}, collection = "/import/{{ .Name }}.collection", x = {{ .X }}, y = {{ .Y }}, width = {{ .W }}, height = {{ .H }}, depth = {{ .Depth }} },
+{{- end }}
+}
+return world
+`))